@@ -1,8 +1,15 @@
 package factory
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/Shelex/split-specs/api/graph/model"
+	"github.com/Shelex/split-specs/domain"
 	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/archive"
 )
 
 func SpecFilesToSpecs(files []*model.SpecFile) []entities.Spec {
@@ -20,6 +27,85 @@ func SpecFilesToSpecs(files []*model.SpecFile) []entities.Spec {
 	return specs
 }
 
+func SessionInputToSession(id string, input model.SessionInput) entities.Session {
+	session := entities.Session{
+		ID: id,
+	}
+
+	if input.AbortAfterFailures != nil {
+		session.AbortAfterFailures = *input.AbortAfterFailures
+	}
+
+	session.Labels = ApiLabelsToLabels(input.Labels)
+
+	if input.Ci != nil {
+		metadata := apiCIMetadataToCIMetadata(*input.Ci)
+		session.CI = &metadata
+	}
+
+	return session
+}
+
+func apiCIMetadataToCIMetadata(input model.CIMetadataInput) entities.CIMetadata {
+	value := func(field *string) string {
+		if field == nil {
+			return ""
+		}
+		return *field
+	}
+
+	return entities.CIMetadata{
+		Provider:    input.Provider,
+		RunID:       value(input.RunID),
+		Commit:      value(input.Commit),
+		Branch:      value(input.Branch),
+		PullRequest: value(input.PullRequest),
+		JobURL:      value(input.JobURL),
+	}
+}
+
+func ciMetadataToApi(labels []entities.Label) *model.CIMetadata {
+	metadata := entities.CIMetadataFromLabels(labels)
+	if metadata == nil {
+		return nil
+	}
+
+	return &model.CIMetadata{
+		Provider:    metadata.Provider,
+		RunID:       metadata.RunID,
+		Commit:      metadata.Commit,
+		Branch:      metadata.Branch,
+		PullRequest: metadata.PullRequest,
+		JobURL:      metadata.JobURL,
+	}
+}
+
+func ApiLabelsToLabels(labels []*model.LabelInput) []entities.Label {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	entityLabels := make([]entities.Label, len(labels))
+	for i, label := range labels {
+		entityLabels[i] = entities.Label{
+			Key:   label.Key,
+			Value: label.Value,
+		}
+	}
+	return entityLabels
+}
+
+func labelsToApi(labels []entities.Label) []*model.Label {
+	apiLabels := make([]*model.Label, len(labels))
+	for i, label := range labels {
+		apiLabels[i] = &model.Label{
+			Key:   label.Key,
+			Value: label.Value,
+		}
+	}
+	return apiLabels
+}
+
 func ProjectSessionsToApiSessions(sessions []entities.SessionWithSpecs) []*model.Session {
 	apiSessions := make([]*model.Session, len(sessions))
 	for i, session := range sessions {
@@ -30,10 +116,79 @@ func ProjectSessionsToApiSessions(sessions []entities.SessionWithSpecs) []*model
 
 func ProjectSessionToApiSession(session entities.SessionWithSpecs) *model.Session {
 	return &model.Session{
-		ID:      session.ID,
-		Start:   int(session.Start),
-		End:     int(session.End),
-		Backlog: specsToApiSpecs(session.Specs),
+		ID:                 session.ID,
+		Start:              int(session.Start),
+		End:                int(session.End),
+		AbortAfterFailures: session.AbortAfterFailures,
+		AbortedBy:          session.AbortedBy,
+		Labels:             labelsToApi(session.Labels),
+		Ci:                 ciMetadataToApi(session.Labels),
+		Backlog:            specsToApiSpecs(session.Specs),
+		Stats:              sessionStatsToApi(domain.CalculateSessionStats(session, time.Now().Unix())),
+	}
+}
+
+func sessionStatsToApi(stats entities.SessionStats) *model.SessionStats {
+	machines := make([]*model.MachineStats, len(stats.Machines))
+	for i, machine := range stats.Machines {
+		machines[i] = &model.MachineStats{
+			Machine:  machine.Machine,
+			BusyTime: int(machine.BusyTime),
+		}
+	}
+
+	return &model.SessionStats{
+		Total:              stats.Total,
+		Started:            stats.Started,
+		Finished:           stats.Finished,
+		Failed:             stats.Failed,
+		Skipped:            stats.Skipped,
+		Elapsed:            int(stats.Elapsed),
+		PredictedRemaining: int(stats.PredictedRemaining),
+		ActiveMachines:     stats.ActiveMachines,
+		Machines:           machines,
+	}
+}
+
+func SessionTimelineToApi(timeline entities.SessionTimeline) *model.SessionTimeline {
+	machines := make([]*model.MachineTimeline, len(timeline.Machines))
+	for i, machine := range timeline.Machines {
+		machines[i] = machineTimelineToApi(machine)
+	}
+
+	return &model.SessionTimeline{
+		SessionID: timeline.SessionID,
+		Machines:  machines,
+		Imbalance: int(timeline.Imbalance),
+	}
+}
+
+func machineTimelineToApi(timeline entities.MachineTimeline) *model.MachineTimeline {
+	specs := make([]*model.SpecInterval, len(timeline.Specs))
+	for i, spec := range timeline.Specs {
+		specs[i] = &model.SpecInterval{
+			File:   spec.FilePath,
+			Start:  int(spec.Start),
+			End:    int(spec.End),
+			Passed: spec.Passed,
+		}
+	}
+
+	gaps := make([]*model.TimeInterval, len(timeline.IdleGaps))
+	for i, gap := range timeline.IdleGaps {
+		gaps[i] = &model.TimeInterval{
+			Start: int(gap.Start),
+			End:   int(gap.End),
+		}
+	}
+
+	return &model.MachineTimeline{
+		Machine:   timeline.Machine,
+		Specs:     specs,
+		IdleGaps:  gaps,
+		BusyTime:  int(timeline.BusyTime),
+		IdleTime:  int(timeline.IdleTime),
+		TotalTime: int(timeline.TotalTime),
 	}
 }
 
@@ -53,10 +208,142 @@ func specToApiSpec(spec entities.Spec) *model.Spec {
 		Start:             int(spec.Start),
 		End:               int(spec.End),
 		Passed:            spec.Passed,
+		Skipped:           spec.Skipped,
 		AssignedTo:        spec.AssignedTo,
 	}
 }
 
+func ApiAnalyticsOptionsToOptions(options *model.AnalyticsOptions) entities.AnalyticsOptions {
+	var analyticsOptions entities.AnalyticsOptions
+	if options == nil {
+		return analyticsOptions
+	}
+	if options.Window != nil {
+		analyticsOptions.Window = *options.Window
+	}
+	if options.Slowest != nil {
+		analyticsOptions.Slowest = *options.Slowest
+	}
+	if options.RegressionThreshold != nil {
+		analyticsOptions.RegressionThreshold = *options.RegressionThreshold
+	}
+	return analyticsOptions
+}
+
+func ProjectAnalyticsToApi(analytics entities.ProjectAnalytics) *model.ProjectAnalytics {
+	trend := make([]*model.SessionDuration, len(analytics.DurationTrend))
+	for i, session := range analytics.DurationTrend {
+		trend[i] = &model.SessionDuration{
+			SessionID: session.SessionID,
+			Start:     int(session.Start),
+			End:       int(session.End),
+			Duration:  int(session.Duration),
+		}
+	}
+
+	slowest := make([]*model.SpecDurationHistory, len(analytics.SlowestSpecs))
+	for i, spec := range analytics.SlowestSpecs {
+		slowest[i] = &model.SpecDurationHistory{
+			File:            spec.FilePath,
+			AverageDuration: int(spec.AverageDuration),
+			History:         specRunDurationsToApi(spec.History),
+		}
+	}
+
+	regressions := make([]*model.SpecRegression, len(analytics.Regressions))
+	for i, regression := range analytics.Regressions {
+		regressions[i] = &model.SpecRegression{
+			File:             regression.FilePath,
+			BaselineDuration: int(regression.BaselineDuration),
+			LatestDuration:   int(regression.LatestDuration),
+			Ratio:            regression.Ratio,
+		}
+	}
+
+	return &model.ProjectAnalytics{
+		Window:        analytics.Window,
+		PassRate:      analytics.PassRate,
+		DurationTrend: trend,
+		SlowestSpecs:  slowest,
+		Regressions:   regressions,
+	}
+}
+
+func specRunDurationsToApi(runs []entities.SpecRunDuration) []*model.SpecRunDuration {
+	apiRuns := make([]*model.SpecRunDuration, len(runs))
+	for i, run := range runs {
+		apiRuns[i] = &model.SpecRunDuration{
+			SessionID: run.SessionID,
+			End:       int(run.End),
+			Duration:  int(run.Duration),
+			Passed:    run.Passed,
+		}
+	}
+	return apiRuns
+}
+
+func SpecHistoryToApi(filePath string, specs []entities.Spec, total int) *model.SpecHistory {
+	runs := make([]*model.SpecRun, len(specs))
+	for i, spec := range specs {
+		var duration int64
+		if spec.End != 0 {
+			duration = spec.End - spec.Start
+		}
+		runs[i] = &model.SpecRun{
+			SessionID: spec.SessionID,
+			Start:     int(spec.Start),
+			End:       int(spec.End),
+			Duration:  int(duration),
+			Machine:   spec.AssignedTo,
+			Passed:    spec.Passed,
+		}
+	}
+
+	return &model.SpecHistory{
+		File:      filePath,
+		TotalRuns: total,
+		Runs:      runs,
+	}
+}
+
+func ApiOrganisationToName(organisation *string) string {
+	if organisation == nil {
+		return ""
+	}
+	return *organisation
+}
+
+func ApiRoleToRole(role *model.Role) string {
+	if role == nil {
+		return entities.RoleMaintainer
+	}
+	return strings.ToLower(role.String())
+}
+
+func ProjectMembersToApi(members []entities.ProjectMember) []*model.ProjectMember {
+	apiMembers := make([]*model.ProjectMember, len(members))
+	for i, member := range members {
+		apiMembers[i] = &model.ProjectMember{
+			Email: member.Email,
+			Role:  model.Role(strings.ToUpper(member.Role)),
+		}
+	}
+	return apiMembers
+}
+
+func OrganisationsToApi(organisations []entities.OrganisationWithMembers) []*model.Organisation {
+	apiOrganisations := make([]*model.Organisation, len(organisations))
+	for i, organisation := range organisations {
+		apiOrganisations[i] = &model.Organisation{
+			Name:     organisation.Name,
+			Role:     model.Role(strings.ToUpper(organisation.Role)),
+			Members:  ProjectMembersToApi(organisation.Members),
+			Projects: organisation.Projects,
+		}
+	}
+	return apiOrganisations
+}
+
 func ApiKeysToApi(apiKeys []entities.ApiKey) []*model.APIKey {
 	keys := make([]*model.APIKey, len(apiKeys))
 	for i, key := range apiKeys {
@@ -66,16 +353,156 @@ func ApiKeysToApi(apiKeys []entities.ApiKey) []*model.APIKey {
 }
 
 func apiKeyToApi(apiKey entities.ApiKey) *model.APIKey {
+	scopes := apiKey.Scopes
+	if scopes == nil {
+		scopes = entities.AllScopes
+	}
+
+	projects := apiKey.Projects
+	if projects == nil {
+		projects = []string{}
+	}
+
 	return &model.APIKey{
-		ID:       apiKey.ID,
-		Name:     apiKey.Name,
-		ExpireAt: int(apiKey.ExpireAt),
+		ID:           apiKey.ID,
+		Name:         apiKey.Name,
+		ExpireAt:     int(apiKey.ExpireAt),
+		Scopes:       scopes,
+		Projects:     projects,
+		LastUsedAt:   int(apiKey.LastUsedAt),
+		LastIP:       apiKey.LastIP,
+		RequestCount: int(apiKey.RequestCount),
 	}
 }
 
-func ApiPaginationToPagination(pagination *model.Pagination) *entities.Pagination {
+func ServiceTokensToApi(tokens []entities.ServiceToken) []*model.ServiceToken {
+	apiTokens := make([]*model.ServiceToken, len(tokens))
+	for i, token := range tokens {
+		apiTokens[i] = &model.ServiceToken{
+			ID:         token.ID,
+			Name:       token.Name,
+			CreatedBy:  token.CreatedBy,
+			CreatedAt:  int(token.CreatedAt),
+			ExpireAt:   int(token.ExpireAt),
+			LastUsedAt: int(token.LastUsedAt),
+			Scopes:     token.Scopes,
+		}
+	}
+	return apiTokens
+}
+
+func ApiPaginationToPagination(pagination *model.Pagination) (*entities.Pagination, error) {
+	if pagination == nil {
+		return nil, nil
+	}
+	if pagination.Offset < 0 || pagination.Limit < 0 {
+		return nil, fmt.Errorf("pagination offset and limit cannot be negative")
+	}
 	return &entities.Pagination{
 		Limit:  pagination.Limit,
 		Offset: pagination.Offset,
+	}, nil
+}
+
+func AuthTokensToApi(tokens entities.AuthTokens) *model.AuthTokens {
+	return &model.AuthTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpireAt:     int(tokens.ExpireAt),
+	}
+}
+
+func AuditLogToApi(entries []entities.AuditEntry, total int) *model.AuditLog {
+	apiEntries := make([]*model.AuditEntry, len(entries))
+	for i, entry := range entries {
+		apiEntries[i] = &model.AuditEntry{
+			Action:    entry.Action,
+			Actor:     entry.ActorEmail,
+			Target:    entry.Target,
+			Timestamp: int(entry.Timestamp),
+			IP:        entry.IP,
+		}
+	}
+
+	return &model.AuditLog{
+		Total:   total,
+		Entries: apiEntries,
+	}
+}
+
+func ApiRetentionPolicyToPolicy(policy *model.RetentionPolicyInput) *entities.RetentionPolicy {
+	if policy == nil {
+		return nil
+	}
+	return &entities.RetentionPolicy{
+		KeepLast:   policy.KeepLast,
+		MaxAgeDays: policy.MaxAgeDays,
+	}
+}
+
+func RetentionPolicyToApi(policy entities.RetentionPolicy) *model.RetentionPolicy {
+	return &model.RetentionPolicy{
+		KeepLast:   policy.KeepLast,
+		MaxAgeDays: policy.MaxAgeDays,
+	}
+}
+
+func RetentionPreviewToApi(policy entities.RetentionPolicy, sessions []entities.Session) *model.RetentionPreview {
+	expired := make([]*model.SessionDuration, len(sessions))
+	for i, session := range sessions {
+		expired[i] = &model.SessionDuration{
+			SessionID: session.ID,
+			Start:     int(session.Start),
+			End:       int(session.End),
+			Duration:  int(session.End - session.Start),
+		}
+	}
+
+	return &model.RetentionPreview{
+		Policy:   RetentionPolicyToApi(policy),
+		Total:    len(sessions),
+		Sessions: expired,
+	}
+}
+
+func ApiArchiveFormatToFormat(format *model.ArchiveFormat) string {
+	if format == nil {
+		return archive.FormatJSON
+	}
+	return strings.ToLower(format.String())
+}
+
+func ArchiveToApi(exported archive.Archive, format string) (string, error) {
+	var buffer bytes.Buffer
+
+	if err := archive.Write(&buffer, exported, format); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func ApiArchiveToArchive(encoded string) (archive.Archive, error) {
+	return archive.Read(strings.NewReader(encoded))
+}
+
+func BaselinesToApi(baselines []entities.SpecBaseline) []*model.SpecBaseline {
+	apiBaselines := make([]*model.SpecBaseline, len(baselines))
+	for i, baseline := range baselines {
+		apiBaselines[i] = &model.SpecBaseline{
+			File:     baseline.FilePath,
+			Duration: int(baseline.Duration),
+		}
+	}
+	return apiBaselines
+}
+
+func EstimationSourceToApi(source *entities.EstimationSource) *model.EstimationSource {
+	if source == nil {
+		return nil
+	}
+	return &model.EstimationSource{
+		ProjectName:      source.ProjectName,
+		Scale:            source.Scale,
+		OverlappingSpecs: source.Overlapping,
 	}
 }
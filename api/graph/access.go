@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/Shelex/split-specs/internal/auth"
+	"github.com/Shelex/split-specs/internal/users"
+)
+
+// authorize returns current user when it is authenticated and token has required scope,
+// empty scope means account management which is available only for user tokens
+func (r *Resolver) authorize(ctx context.Context, scope string) (*users.User, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, &users.AccessDeniedError{}
+	}
+
+	if scope == "" && user.IsApiKey() {
+		return nil, &users.AccessDeniedError{}
+	}
+
+	if !user.HasScope(scope) {
+		return nil, &users.AccessDeniedError{}
+	}
+
+	return user, nil
+}
+
+// authorizeProject additionally checks that api key is not restricted to other projects
+func (r *Resolver) authorizeProject(ctx context.Context, scope string, organisationName string, projectName string) (*users.User, error) {
+	user, err := r.authorize(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsApiKey() || len(user.Projects) == 0 {
+		return user, nil
+	}
+
+	// deleted projects are resolved as well, so restricted api key is able to restore them
+	projectID, err := r.SplitService.FindProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil || !user.CanAccessProject(projectID) {
+		return nil, &users.AccessDeniedError{}
+	}
+
+	return user, nil
+}
+
+// authorizeSession additionally checks that api key is not restricted to other projects
+func (r *Resolver) authorizeSession(ctx context.Context, scope string, sessionID string) (*users.User, error) {
+	user, err := r.authorize(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsApiKey() || len(user.Projects) == 0 {
+		return user, nil
+	}
+
+	session, err := r.SplitService.Repository.GetSession(sessionID)
+	if err != nil || !user.CanAccessProject(session.ProjectID) {
+		return nil, &users.AccessDeniedError{}
+	}
+
+	return user, nil
+}
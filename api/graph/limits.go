@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/Shelex/split-specs/api/graph/model"
+	"github.com/Shelex/split-specs/internal/auth"
+	"github.com/Shelex/split-specs/internal/users"
+	"github.com/Shelex/split-specs/pkg/limiter"
+)
+
+// credentialMutations are available without token and run bcrypt or send emails
+var credentialMutations = map[string]bool{
+	"login":                true,
+	"register":             true,
+	"requestPasswordReset": true,
+	"resetPassword":        true,
+}
+
+// CredentialRateLimit limits credential mutations by client ip and by account email,
+// client ip is taken from connection or trusted proxy only, so rotating proxy headers does not reset the limit
+func CredentialRateLimit(perIP *limiter.Limiter, perAccount *limiter.Limiter) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		field := graphql.GetFieldContext(ctx)
+		if field == nil || field.Object != "Mutation" || !credentialMutations[field.Field.Name] {
+			return next(ctx)
+		}
+
+		if err := perIP.Allow(auth.IPForContext(ctx)); err != nil {
+			return nil, err
+		}
+
+		if email := accountEmail(field.Args); email != "" {
+			if err := perAccount.Allow(email); err != nil {
+				return nil, err
+			}
+		}
+
+		return next(ctx)
+	}
+}
+
+func accountEmail(args map[string]interface{}) string {
+	if input, ok := args["input"].(model.User); ok {
+		return input.Email
+	}
+	email, _ := args["email"].(string)
+	return email
+}
+
+// Quotas are token buckets for root operations,
+// operations without own quota share Default bucket
+type Quotas struct {
+	Default    *limiter.TokenBucket
+	Operations map[string]*limiter.TokenBucket
+}
+
+// OperationQuota limits root operations per api key, service token or user
+func OperationQuota(quotas Quotas) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		field := graphql.GetFieldContext(ctx)
+		if field == nil || (field.Object != "Query" && field.Object != "Mutation") {
+			return next(ctx)
+		}
+
+		user := auth.ForContext(ctx)
+		if user == nil {
+			return next(ctx)
+		}
+
+		operation := field.Field.Name
+		bucket, ok := quotas.Operations[operation]
+		if !ok {
+			operation = "default"
+			bucket = quotas.Default
+		}
+
+		if err := bucket.Allow(principalKey(user) + ":" + operation); err != nil {
+			return nil, err
+		}
+
+		return next(ctx)
+	}
+}
+
+func principalKey(user *users.User) string {
+	switch {
+	case user.IsService():
+		return "service:" + user.ServiceTokenID
+	case user.ApiKeyID != "":
+		return "key:" + user.ApiKeyID
+	default:
+		return "user:" + user.ID
+	}
+}
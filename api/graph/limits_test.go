@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/Shelex/split-specs/internal/auth"
+	"github.com/Shelex/split-specs/pkg/limiter"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// loginServer runs login mutation behind auth middleware and credential rate limit, limited requests get 429
+func loginServer(limit int) http.Handler {
+	rateLimit := CredentialRateLimit(
+		limiter.New(limiter.NewInMemStore(), "ip:", limiter.Rate{Limit: limit, Window: time.Minute}),
+		limiter.New(limiter.NewInMemStore(), "account:", limiter.Rate{Limit: 100, Window: time.Minute}),
+	)
+
+	return auth.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := graphql.WithFieldContext(r.Context(), &graphql.FieldContext{
+			Object: "Mutation",
+			Field:  graphql.CollectedField{Field: &ast.Field{Name: "login"}},
+			Args:   map[string]interface{}{},
+		})
+
+		_, err := rateLimit(ctx, func(ctx context.Context) (interface{}, error) {
+			return true, nil
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+}
+
+func login(server http.Handler, remoteAddr string, headers map[string]string) int {
+	request := httptest.NewRequest(http.MethodPost, "/query", nil)
+	request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestCredentialRateLimitIgnoresForgedHeaders(t *testing.T) {
+	defer func() { _ = auth.SetTrustedProxy(auth.ProxyNone) }()
+
+	forged := []map[string]string{
+		{"X-Forwarded-For": "10.0.0.1"},
+		{"X-Real-IP": "10.0.0.2"},
+		{"True-Client-IP": "10.0.0.3"},
+		{"X-Appengine-User-IP": "10.0.0.4"},
+	}
+
+	tests := []struct {
+		name   string
+		proxy  string
+		remote string
+		// trusted is header set by trusted proxy after client headers
+		trusted map[string]string
+	}{
+		{name: "without proxy", proxy: auth.ProxyNone, remote: "203.0.113.7:5000"},
+		{name: "forwarded proxy", proxy: auth.ProxyForwarded, remote: "192.168.0.1:5000", trusted: map[string]string{"X-Forwarded-For": "203.0.113.7"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := auth.SetTrustedProxy(test.proxy); err != nil {
+				t.Fatal(err)
+			}
+
+			server := loginServer(2)
+
+			for index, headers := range forged {
+				request := map[string]string{}
+				for name, value := range headers {
+					request[name] = value
+				}
+				for name, value := range test.trusted {
+					// proxy appends real client address to client supplied header
+					if forgedValue, ok := request[name]; ok {
+						value = forgedValue + ", " + value
+					}
+					request[name] = value
+				}
+
+				code := login(server, test.remote, request)
+
+				if index < 2 && code != http.StatusOK {
+					t.Fatalf("request %d: expected to pass, got %d", index, code)
+				}
+				if index >= 2 && code != http.StatusTooManyRequests {
+					t.Fatalf("request %d with forged %v: expected to be limited, got %d", index, headers, code)
+				}
+			}
+		})
+	}
+}
@@ -2,10 +2,64 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type AnalyticsOptions struct {
+	Window              *int     `json:"window"`
+	Slowest             *int     `json:"slowest"`
+	RegressionThreshold *float64 `json:"regressionThreshold"`
+}
+
 type APIKey struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	ExpireAt int    `json:"expireAt"`
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	ExpireAt     int      `json:"expireAt"`
+	Scopes       []string `json:"scopes"`
+	Projects     []string `json:"projects"`
+	LastUsedAt   int      `json:"lastUsedAt"`
+	LastIP       string   `json:"lastIp"`
+	RequestCount int      `json:"requestCount"`
+}
+
+type AuditEntry struct {
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	Target    string `json:"target"`
+	Timestamp int    `json:"timestamp"`
+	IP        string `json:"ip"`
+}
+
+type AuditLog struct {
+	Total   int           `json:"total"`
+	Entries []*AuditEntry `json:"entries"`
+}
+
+type AuthTokens struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpireAt     int    `json:"expireAt"`
+}
+
+type CIMetadata struct {
+	Provider    string `json:"provider"`
+	RunID       string `json:"runId"`
+	Commit      string `json:"commit"`
+	Branch      string `json:"branch"`
+	PullRequest string `json:"pullRequest"`
+	JobURL      string `json:"jobUrl"`
+}
+
+type CIMetadataInput struct {
+	Provider    string  `json:"provider"`
+	RunID       *string `json:"runId"`
+	Commit      *string `json:"commit"`
+	Branch      *string `json:"branch"`
+	PullRequest *string `json:"pullRequest"`
+	JobURL      *string `json:"jobUrl"`
 }
 
 type ChangePasswordInput struct {
@@ -13,27 +67,119 @@ type ChangePasswordInput struct {
 	NewPassword string `json:"newPassword"`
 }
 
+type EstimationSource struct {
+	ProjectName      string  `json:"projectName"`
+	Scale            float64 `json:"scale"`
+	OverlappingSpecs int     `json:"overlappingSpecs"`
+}
+
+type Label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type LabelInput struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type MachineStats struct {
+	Machine  string `json:"machine"`
+	BusyTime int    `json:"busyTime"`
+}
+
+type MachineTimeline struct {
+	Machine   string          `json:"machine"`
+	Specs     []*SpecInterval `json:"specs"`
+	IdleGaps  []*TimeInterval `json:"idleGaps"`
+	BusyTime  int             `json:"busyTime"`
+	IdleTime  int             `json:"idleTime"`
+	TotalTime int             `json:"totalTime"`
+}
+
 type NextOptions struct {
 	MachineID      *string `json:"machineId"`
 	PreviousPassed *bool   `json:"previousPassed"`
 }
 
+type Organisation struct {
+	Name     string           `json:"name"`
+	Role     Role             `json:"role"`
+	Members  []*ProjectMember `json:"members"`
+	Projects []string         `json:"projects"`
+}
+
 type Pagination struct {
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
 }
 
 type Project struct {
-	ProjectName   string     `json:"projectName"`
-	Sessions      []*Session `json:"sessions"`
-	TotalSessions int        `json:"totalSessions"`
+	ProjectName      string            `json:"projectName"`
+	Sessions         []*Session        `json:"sessions"`
+	TotalSessions    int               `json:"totalSessions"`
+	Members          []*ProjectMember  `json:"members"`
+	Retention        *RetentionPolicy  `json:"retention"`
+	Baseline         []*SpecBaseline   `json:"baseline"`
+	EstimationSource *EstimationSource `json:"estimationSource"`
+}
+
+type ProjectAnalytics struct {
+	Window        int                    `json:"window"`
+	PassRate      float64                `json:"passRate"`
+	DurationTrend []*SessionDuration     `json:"durationTrend"`
+	SlowestSpecs  []*SpecDurationHistory `json:"slowestSpecs"`
+	Regressions   []*SpecRegression      `json:"regressions"`
+}
+
+type ProjectMember struct {
+	Email string `json:"email"`
+	Role  Role   `json:"role"`
+}
+
+type RetentionPolicy struct {
+	KeepLast   int `json:"keepLast"`
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+type RetentionPolicyInput struct {
+	KeepLast   int `json:"keepLast"`
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+type RetentionPreview struct {
+	Policy   *RetentionPolicy   `json:"policy"`
+	Total    int                `json:"total"`
+	Sessions []*SessionDuration `json:"sessions"`
+}
+
+type ServiceToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	CreatedBy  string   `json:"createdBy"`
+	CreatedAt  int      `json:"createdAt"`
+	ExpireAt   int      `json:"expireAt"`
+	LastUsedAt int      `json:"lastUsedAt"`
+	Scopes     []string `json:"scopes"`
 }
 
 type Session struct {
-	ID      string  `json:"id"`
-	Start   int     `json:"start"`
-	End     int     `json:"end"`
-	Backlog []*Spec `json:"backlog"`
+	ID                 string        `json:"id"`
+	Start              int           `json:"start"`
+	End                int           `json:"end"`
+	AbortAfterFailures int           `json:"abortAfterFailures"`
+	AbortedBy          string        `json:"abortedBy"`
+	Labels             []*Label      `json:"labels"`
+	Ci                 *CIMetadata   `json:"ci"`
+	Backlog            []*Spec       `json:"backlog"`
+	Stats              *SessionStats `json:"stats"`
+}
+
+type SessionDuration struct {
+	SessionID string `json:"sessionId"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Duration  int    `json:"duration"`
 }
 
 type SessionInfo struct {
@@ -42,8 +188,34 @@ type SessionInfo struct {
 }
 
 type SessionInput struct {
-	ProjectName string      `json:"projectName"`
-	SpecFiles   []*SpecFile `json:"specFiles"`
+	ProjectName        string      `json:"projectName"`
+	SpecFiles          []*SpecFile `json:"specFiles"`
+	AbortAfterFailures *int        `json:"abortAfterFailures"`
+	Organisation       *string     `json:"organisation"`
+	// arbitrary key-value metadata like branch, commit or environment, keys with ci. prefix are reserved
+	Labels []*LabelInput `json:"labels"`
+	// up to 2 label keys, only history of sessions with the same values of these labels is used for estimation
+	EstimateBy []string `json:"estimateBy"`
+	// metadata of CI build creating session, stored as labels with ci. prefix
+	Ci *CIMetadataInput `json:"ci"`
+}
+
+type SessionStats struct {
+	Total              int             `json:"total"`
+	Started            int             `json:"started"`
+	Finished           int             `json:"finished"`
+	Failed             int             `json:"failed"`
+	Skipped            int             `json:"skipped"`
+	Elapsed            int             `json:"elapsed"`
+	PredictedRemaining int             `json:"predictedRemaining"`
+	ActiveMachines     int             `json:"activeMachines"`
+	Machines           []*MachineStats `json:"machines"`
+}
+
+type SessionTimeline struct {
+	SessionID string             `json:"sessionId"`
+	Machines  []*MachineTimeline `json:"machines"`
+	Imbalance int                `json:"imbalance"`
 }
 
 type Spec struct {
@@ -52,15 +224,152 @@ type Spec struct {
 	Start             int    `json:"start"`
 	End               int    `json:"end"`
 	Passed            bool   `json:"passed"`
+	Skipped           bool   `json:"skipped"`
 	AssignedTo        string `json:"assignedTo"`
 }
 
+type SpecBaseline struct {
+	File     string `json:"file"`
+	Duration int    `json:"duration"`
+}
+
+type SpecDurationHistory struct {
+	File            string             `json:"file"`
+	AverageDuration int                `json:"averageDuration"`
+	History         []*SpecRunDuration `json:"history"`
+}
+
 type SpecFile struct {
 	Tests    []string `json:"tests"`
 	FilePath string   `json:"filePath"`
 }
 
+type SpecHistory struct {
+	File      string     `json:"file"`
+	TotalRuns int        `json:"totalRuns"`
+	Runs      []*SpecRun `json:"runs"`
+}
+
+type SpecInterval struct {
+	File   string `json:"file"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Passed bool   `json:"passed"`
+}
+
+type SpecRegression struct {
+	File             string  `json:"file"`
+	BaselineDuration int     `json:"baselineDuration"`
+	LatestDuration   int     `json:"latestDuration"`
+	Ratio            float64 `json:"ratio"`
+}
+
+type SpecRun struct {
+	SessionID string `json:"sessionId"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Duration  int    `json:"duration"`
+	Machine   string `json:"machine"`
+	Passed    bool   `json:"passed"`
+}
+
+type SpecRunDuration struct {
+	SessionID string `json:"sessionId"`
+	End       int    `json:"end"`
+	Duration  int    `json:"duration"`
+	Passed    bool   `json:"passed"`
+}
+
+type TimeInterval struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
 type User struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatJSON   ArchiveFormat = "JSON"
+	ArchiveFormatNdjson ArchiveFormat = "NDJSON"
+)
+
+var AllArchiveFormat = []ArchiveFormat{
+	ArchiveFormatJSON,
+	ArchiveFormatNdjson,
+}
+
+func (e ArchiveFormat) IsValid() bool {
+	switch e {
+	case ArchiveFormatJSON, ArchiveFormatNdjson:
+		return true
+	}
+	return false
+}
+
+func (e ArchiveFormat) String() string {
+	return string(e)
+}
+
+func (e *ArchiveFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ArchiveFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ArchiveFormat", str)
+	}
+	return nil
+}
+
+func (e ArchiveFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleOwner      Role = "OWNER"
+	RoleMaintainer Role = "MAINTAINER"
+	RoleViewer     Role = "VIEWER"
+)
+
+var AllRole = []Role{
+	RoleOwner,
+	RoleMaintainer,
+	RoleViewer,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleOwner, RoleMaintainer, RoleViewer:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"github.com/Shelex/split-specs/domain"
+	"github.com/Shelex/split-specs/pkg/mailer"
 )
 
 // This file will not be regenerated automatically.
@@ -10,10 +11,12 @@ import (
 
 type Resolver struct {
 	SplitService domain.SplitService
+	Mailer       mailer.Mailer
 }
 
-func NewResolver(svc domain.SplitService) *Resolver {
+func NewResolver(svc domain.SplitService, sender mailer.Mailer) *Resolver {
 	return &Resolver{
 		SplitService: svc,
+		Mailer:       sender,
 	}
 }
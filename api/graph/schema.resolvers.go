@@ -5,11 +5,13 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Shelex/split-specs/api/factory"
 	"github.com/Shelex/split-specs/api/graph/generated"
 	"github.com/Shelex/split-specs/api/graph/model"
+	"github.com/Shelex/split-specs/domain"
 	"github.com/Shelex/split-specs/entities"
 	"github.com/Shelex/split-specs/internal/auth"
 	"github.com/Shelex/split-specs/internal/users"
@@ -18,16 +20,18 @@ import (
 )
 
 func (r *mutationResolver) AddSession(ctx context.Context, session model.SessionInput) (*model.SessionInfo, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, &users.AccessDeniedError{}
+	organisation := factory.ApiOrganisationToName(session.Organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeSessionCreate, organisation, session.ProjectName)
+	if err != nil {
+		return nil, err
 	}
 
 	id, _ := gonanoid.New()
 
 	specs := factory.SpecFilesToSpecs(session.SpecFiles)
 
-	if err := r.SplitService.AddSession(user.ID, session.ProjectName, id, specs); err != nil {
+	if err := r.SplitService.AddSession(users.UserToEntityUser(*user), session.ProjectName, organisation, factory.SessionInputToSession(id, session), specs, session.EstimateBy); err != nil {
 		return nil, err
 	}
 
@@ -37,9 +41,9 @@ func (r *mutationResolver) AddSession(ctx context.Context, session model.Session
 	}, nil
 }
 
-func (r *mutationResolver) Register(ctx context.Context, input model.User) (string, error) {
+func (r *mutationResolver) Register(ctx context.Context, input model.User) (*model.AuthTokens, error) {
 	if input.Email == "" || input.Password == "" {
-		return "", &users.InvalidEmailOrPassordError{}
+		return nil, &users.InvalidEmailOrPassordError{}
 	}
 
 	id, _ := gonanoid.New()
@@ -51,147 +55,429 @@ func (r *mutationResolver) Register(ctx context.Context, input model.User) (stri
 	}
 
 	if !user.EmailIsValid() {
-		return "", &users.InvalidEmailFormat{}
+		return nil, &users.InvalidEmailFormat{}
 	}
 
 	if user.Exist() {
-		return "", &users.WrongEmailOrPasswordError{}
+		return nil, &users.WrongEmailOrPasswordError{}
 	}
 
 	if err := user.Create(); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	token, err := jwt.GenerateToken(user)
+	tokens, err := auth.IssueTokens(user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return token, nil
+	return factory.AuthTokensToApi(tokens), nil
 }
 
-func (r *mutationResolver) Login(ctx context.Context, input model.User) (string, error) {
+func (r *mutationResolver) Login(ctx context.Context, input model.User) (*model.AuthTokens, error) {
 	user := users.User{
 		Email:    input.Email,
 		Password: input.Password,
 	}
 
-	correct := user.Authenticate()
-	if !correct {
-		return "", &users.WrongEmailOrPasswordError{}
+	if err := user.Authenticate(); err != nil {
+		return nil, err
 	}
 
 	dbUser, err := r.SplitService.Repository.GetUserByEmail(input.Email)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	user.ID = dbUser.ID
 
-	token, err := jwt.GenerateToken(user)
+	tokens, err := auth.IssueTokens(user)
+	if err != nil {
+		return nil, err
+	}
+	return factory.AuthTokensToApi(tokens), nil
+}
+
+func (r *mutationResolver) RefreshToken(ctx context.Context, token string) (*model.AuthTokens, error) {
+	tokens, err := auth.RefreshTokens(token)
+	if err != nil {
+		return nil, err
+	}
+	return factory.AuthTokensToApi(tokens), nil
+}
+
+func (r *mutationResolver) LogoutEverywhere(ctx context.Context) (string, error) {
+	user, err := r.authorize(ctx, "")
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+
+	if err := r.SplitService.LogoutEverywhere(users.UserToEntityUser(*user)); err != nil {
+		return "", err
+	}
+
+	return "signed out everywhere", nil
 }
 
 func (r *mutationResolver) ChangePassword(ctx context.Context, input model.ChangePasswordInput) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
 	}
 
-	if err := user.ChangePassword(input.Password, input.NewPassword); err != nil {
+	if err := r.SplitService.ChangePassword(users.UserToEntityUser(*user), input.Password, input.NewPassword); err != nil {
 		return "", err
 	}
 
-	return "password changed", nil
+	return "password changed, please sign in again", nil
 }
 
-func (r *mutationResolver) ShareProject(ctx context.Context, email string, projectName string) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (string, error) {
+	if err := auth.RequestPasswordReset(r.Mailer, email); err != nil {
+		return "", err
 	}
-	if err := r.SplitService.InviteUserToProject(users.UserToEntityUser(*user), email, projectName); err != nil {
+	return "password reset instructions are sent in case user exists", nil
+}
+
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (string, error) {
+	if err := r.SplitService.ResetPassword(auth.PasswordResetID(token), newPassword, auth.IPForContext(ctx)); err != nil {
+		return "", err
+	}
+
+	return "password changed, please sign in again", nil
+}
+
+func (r *mutationResolver) ShareProject(ctx context.Context, email string, projectName string, role *model.Role, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+	if err := r.SplitService.InviteUserToProject(users.UserToEntityUser(*user), email, projectName, organisationName, factory.ApiRoleToRole(role)); err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("shared project %s with %s", projectName, email), nil
 }
 
+func (r *mutationResolver) ChangeMemberRole(ctx context.Context, projectName string, email string, role model.Role, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	newRole := factory.ApiRoleToRole(&role)
+
+	if err := r.SplitService.ChangeMemberRole(users.UserToEntityUser(*user), projectName, organisationName, email, newRole); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("changed role of %s in project %s to %s", email, projectName, newRole), nil
+}
+
+func (r *mutationResolver) RemoveMember(ctx context.Context, projectName string, email string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.RemoveMember(users.UserToEntityUser(*user), projectName, organisationName, email); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("removed %s from project %s", email, projectName), nil
+}
+
+func (r *mutationResolver) LeaveProject(ctx context.Context, projectName string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.LeaveProject(users.UserToEntityUser(*user), projectName, organisationName); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("left project %s", projectName), nil
+}
+
+func (r *mutationResolver) CreateOrganisation(ctx context.Context, name string) (string, error) {
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.CreateOrganisation(users.UserToEntityUser(*user), name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("organisation %s created", name), nil
+}
+
+func (r *mutationResolver) AddOrganisationMember(ctx context.Context, organisation string, email string, role *model.Role) (string, error) {
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.AddOrganisationMember(users.UserToEntityUser(*user), organisation, email, factory.ApiRoleToRole(role)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("added %s to organisation %s", email, organisation), nil
+}
+
+func (r *mutationResolver) RemoveOrganisationMember(ctx context.Context, organisation string, email string) (string, error) {
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.RemoveOrganisationMember(users.UserToEntityUser(*user), organisation, email); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("removed %s from organisation %s", email, organisation), nil
+}
+
+func (r *mutationResolver) TransferProject(ctx context.Context, projectName string, organisation string, fromOrganisation *string) (string, error) {
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.TransferProject(users.UserToEntityUser(*user), projectName, factory.ApiOrganisationToName(fromOrganisation), organisation); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("project %s transferred to organisation %s", projectName, organisation), nil
+}
+
+func (r *mutationResolver) CancelSession(ctx context.Context, sessionID string) (string, error) {
+	user, err := r.authorizeSession(ctx, entities.ScopeSessionManage, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.CancelSession(users.UserToEntityUser(*user), sessionID); err != nil {
+		return "", err
+	}
+	return "session cancelled", nil
+}
+
 func (r *mutationResolver) DeleteSession(ctx context.Context, sessionID string) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
+	user, err := r.authorizeSession(ctx, entities.ScopeSessionManage, sessionID)
+	if err != nil {
+		return "", err
 	}
 
-	if err := r.SplitService.Repository.DeleteSession(user.Email, sessionID); err != nil {
+	if err := r.SplitService.DeleteSession(users.UserToEntityUser(*user), sessionID); err != nil {
 		return "", err
 	}
 	return "session deleted", nil
 }
 
-func (r *mutationResolver) DeleteProject(ctx context.Context, projectName string) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
-	}
+func (r *mutationResolver) DeleteProject(ctx context.Context, projectName string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
 
-	projectID, err := r.SplitService.Repository.GetUserProjectIDByName(user.ID, projectName)
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
 	if err != nil {
 		return "", err
 	}
 
-	if err := r.SplitService.Repository.DeleteProject(user.Email, projectID); err != nil {
+	if err := r.SplitService.DeleteProject(users.UserToEntityUser(*user), projectName, organisationName); err != nil {
 		return "", err
 	}
 
 	return "project deleted", nil
 }
 
-func (r *mutationResolver) AddAPIKey(ctx context.Context, name string, expireAt int) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
+func (r *mutationResolver) RestoreSession(ctx context.Context, sessionID string) (string, error) {
+	user, err := r.authorizeSession(ctx, entities.ScopeSessionManage, sessionID)
+	if err != nil {
+		return "", err
 	}
 
-	id, _ := gonanoid.New()
+	if err := r.SplitService.RestoreSession(users.UserToEntityUser(*user), sessionID); err != nil {
+		return "", err
+	}
+	return "session restored", nil
+}
 
-	apiKey := entities.ApiKey{
-		ID:       id,
-		UserID:   user.ID,
-		Name:     name,
-		ExpireAt: int64(expireAt),
+func (r *mutationResolver) RestoreProject(ctx context.Context, projectName string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
 	}
 
-	if err := r.SplitService.Repository.CreateApiKey(user.ID, apiKey); err != nil {
+	if err := r.SplitService.RestoreProject(users.UserToEntityUser(*user), projectName, organisationName); err != nil {
 		return "", err
 	}
 
-	token, err := jwt.GenerateApiKey(*user, apiKey)
+	return "project restored", nil
+}
+
+func (r *mutationResolver) SetRetentionPolicy(ctx context.Context, projectName string, policy model.RetentionPolicyInput, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
 	if err != nil {
 		return "", err
 	}
 
-	return token, nil
+	if err := r.SplitService.SetRetentionPolicy(users.UserToEntityUser(*user), projectName, organisationName, *factory.ApiRetentionPolicyToPolicy(&policy)); err != nil {
+		return "", err
+	}
+
+	return "retention policy updated", nil
 }
 
-func (r *mutationResolver) DeleteAPIKey(ctx context.Context, keyID string) (string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return "", &users.AccessDeniedError{}
+func (r *mutationResolver) ExportProject(ctx context.Context, projectName string, format *model.ArchiveFormat, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	exported, err := r.SplitService.ExportProject(users.UserToEntityUser(*user), projectName, organisationName)
+	if err != nil {
+		return "", err
+	}
+
+	return factory.ArchiveToApi(exported, factory.ApiArchiveFormatToFormat(format))
+}
+
+func (r *mutationResolver) ImportProject(ctx context.Context, archive string, projectName *string) (string, error) {
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	imported, err := factory.ApiArchiveToArchive(archive)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	if projectName != nil {
+		name = *projectName
+	}
+
+	return r.SplitService.ImportProject(users.UserToEntityUser(*user), imported, name, false)
+}
+
+func (r *mutationResolver) UploadBaseline(ctx context.Context, projectName string, timings string, organisation *string) ([]*model.SpecBaseline, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines, err := r.SplitService.ImportBaseline(users.UserToEntityUser(*user), projectName, organisationName, timings)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.BaselinesToApi(baselines), nil
+}
+
+func (r *mutationResolver) SetEstimationSource(ctx context.Context, projectName string, sourceProjectName *string, organisation *string, sourceOrganisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	source := ""
+	if sourceProjectName != nil {
+		source = *sourceProjectName
+	}
+	sourceOrganisationName := factory.ApiOrganisationToName(sourceOrganisation)
+
+	// restricted api key should have access to source project as well
+	if source != "" {
+		if _, err := r.authorizeProject(ctx, entities.ScopeProjectRead, sourceOrganisationName, source); err != nil {
+			return "", err
+		}
+	}
+
+	if err := r.SplitService.SetEstimationSource(users.UserToEntityUser(*user), projectName, organisationName, source, sourceOrganisationName); err != nil {
+		return "", err
+	}
+
+	if source == "" {
+		return "estimation source removed", nil
+	}
+	return "estimation source updated", nil
+}
+
+func (r *mutationResolver) AddAPIKey(ctx context.Context, name string, expireAt int, scopes []string, projects []string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	apiKey, err := r.SplitService.AddApiKey(users.UserToEntityUser(*user), name, int64(expireAt), scopes, projects, organisationName)
+	if err != nil {
+		return "", err
 	}
 
-	err := r.SplitService.Repository.DeleteApiKey(user.ID, keyID)
+	return jwt.GenerateApiKey(*user, apiKey)
+}
+
+func (r *mutationResolver) DeleteAPIKey(ctx context.Context, keyID string) (string, error) {
+	user, err := r.authorize(ctx, "")
 	if err != nil {
 		return "", err
 	}
 
+	if err := r.SplitService.DeleteApiKey(users.UserToEntityUser(*user), keyID); err != nil {
+		return "", err
+	}
+
 	return "apiKey deleted", nil
 }
 
+func (r *mutationResolver) AddServiceToken(ctx context.Context, projectName string, name string, expireAt int, scopes []string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	serviceToken, err := r.SplitService.AddServiceToken(users.UserToEntityUser(*user), projectName, organisationName, name, int64(expireAt), scopes)
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.GenerateServiceToken(serviceToken)
+}
+
+func (r *mutationResolver) DeleteServiceToken(ctx context.Context, projectName string, tokenID string, organisation *string) (string, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.SplitService.DeleteServiceToken(users.UserToEntityUser(*user), projectName, organisationName, tokenID); err != nil {
+		return "", err
+	}
+
+	return "service token deleted", nil
+}
+
 func (r *queryResolver) NextSpec(ctx context.Context, sessionID string, options *model.NextOptions) (string, error) {
-	if user := auth.ForContext(ctx); user == nil {
-		return "", &users.AccessDeniedError{}
+	user, err := r.authorizeSession(ctx, entities.ScopeSpecNext, sessionID)
+	if err != nil {
+		return "", err
 	}
 	machine := "default"
 	if options != nil && options.MachineID != nil {
@@ -203,50 +489,156 @@ func (r *queryResolver) NextSpec(ctx context.Context, sessionID string, options
 		previousSpecPassed = *options.PreviousPassed
 	}
 
-	next, err := r.SplitService.Next(sessionID, machine, previousSpecPassed)
+	next, err := r.SplitService.Next(users.UserToEntityUser(*user), sessionID, machine, previousSpecPassed)
 	if err != nil {
+		var aborted *domain.SessionAbortedError
+		if errors.As(err, &aborted) {
+			return "", aborted
+		}
 		return "", fmt.Errorf("failed to receive next spec: %s", err)
 	}
 	return next, nil
 }
 
-func (r *queryResolver) Project(ctx context.Context, name string, pagination *model.Pagination) (*model.Project, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, &users.AccessDeniedError{}
+func (r *queryResolver) Project(ctx context.Context, name string, pagination *model.Pagination, labels []*model.LabelInput, organisation *string) (*model.Project, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	page, err := factory.ApiPaginationToPagination(pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectRead, organisationName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := r.SplitService.GetProjectIDByName(user.ID, organisationName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, total, err := r.SplitService.GetProjectSessions(projectID, factory.ApiLabelsToLabels(labels), page)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := r.SplitService.GetProjectMembers(projectID)
+	if err != nil {
+		return nil, err
 	}
 
-	projectID, err := r.SplitService.Repository.GetUserProjectIDByName(user.ID, name)
+	project, err := r.SplitService.Repository.GetProjectByID(projectID)
 	if err != nil {
 		return nil, err
 	}
 
-	sessions, total, err := r.SplitService.Repository.GetProjectSessions(projectID, factory.ApiPaginationToPagination(pagination))
+	baselines, err := r.SplitService.GetBaseline(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := r.SplitService.GetEstimationSource(user.ID, projectID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &model.Project{
-		ProjectName:   name,
-		Sessions:      factory.ProjectSessionsToApiSessions(sessions),
-		TotalSessions: total,
+		ProjectName:      name,
+		Sessions:         factory.ProjectSessionsToApiSessions(sessions),
+		TotalSessions:    total,
+		Members:          factory.ProjectMembersToApi(members),
+		Retention:        factory.RetentionPolicyToApi(project.Retention()),
+		Baseline:         factory.BaselinesToApi(baselines),
+		EstimationSource: factory.EstimationSourceToApi(source),
 	}, nil
 }
 
 func (r *queryResolver) Projects(ctx context.Context) ([]string, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, &users.AccessDeniedError{}
+	user, err := r.authorize(ctx, entities.ScopeProjectRead)
+	if err != nil {
+		return nil, err
 	}
-	return r.SplitService.GetProjectList(users.UserToEntityUser(*user))
+
+	projects, err := r.SplitService.GetProjectList(users.UserToEntityUser(*user))
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsApiKey() || len(user.Projects) == 0 {
+		return projects, nil
+	}
+
+	return r.SplitService.GetProjectNames(user.Projects), nil
+}
+
+func (r *queryResolver) Organisations(ctx context.Context) ([]*model.Organisation, error) {
+	user, err := r.authorize(ctx, entities.ScopeProjectRead)
+	if err != nil {
+		return nil, err
+	}
+
+	organisations, err := r.SplitService.GetUserOrganisations(users.UserToEntityUser(*user))
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.OrganisationsToApi(organisations), nil
+}
+
+func (r *queryResolver) ProjectAnalytics(ctx context.Context, name string, options *model.AnalyticsOptions, organisation *string) (*model.ProjectAnalytics, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectRead, organisationName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := r.SplitService.GetProjectIDByName(user.ID, organisationName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics, err := r.SplitService.GetProjectAnalytics(projectID, factory.ApiAnalyticsOptionsToOptions(options))
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.ProjectAnalyticsToApi(analytics), nil
+}
+
+func (r *queryResolver) SpecHistory(ctx context.Context, projectName string, filePath string, pagination *model.Pagination, organisation *string) (*model.SpecHistory, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	page, err := factory.ApiPaginationToPagination(pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectRead, organisationName, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := r.SplitService.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, total, err := r.SplitService.Repository.GetSpecHistory(projectID, filePath, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.SpecHistoryToApi(filePath, specs, total), nil
 }
 
 func (r *queryResolver) Session(ctx context.Context, sessionID string) (*model.Session, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, &users.AccessDeniedError{}
+	user, err := r.authorizeSession(ctx, entities.ScopeProjectRead, sessionID)
+	if err != nil {
+		return nil, err
 	}
-	session, err := r.SplitService.Repository.GetSessionWithSpecs(sessionID)
+	session, err := r.SplitService.GetSession(users.UserToEntityUser(*user), sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -254,10 +646,24 @@ func (r *queryResolver) Session(ctx context.Context, sessionID string) (*model.S
 	return factory.ProjectSessionToApiSession(session), nil
 }
 
+func (r *queryResolver) SessionTimeline(ctx context.Context, sessionID string) (*model.SessionTimeline, error) {
+	user, err := r.authorizeSession(ctx, entities.ScopeProjectRead, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline, err := r.SplitService.GetSessionTimeline(users.UserToEntityUser(*user), sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.SessionTimelineToApi(timeline), nil
+}
+
 func (r *queryResolver) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, &users.AccessDeniedError{}
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return nil, err
 	}
 
 	keys, err := r.SplitService.Repository.GetApiKeys(user.ID)
@@ -265,9 +671,80 @@ func (r *queryResolver) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error)
 		return nil, err
 	}
 
+	for index, key := range keys {
+		if len(key.Projects) > 0 {
+			keys[index].Projects = r.SplitService.GetProjectNames(key.Projects)
+		}
+	}
+
 	return factory.ApiKeysToApi(keys), nil
 }
 
+func (r *queryResolver) ServiceTokens(ctx context.Context, projectName string, organisation *string) ([]*model.ServiceToken, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorize(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := r.SplitService.GetServiceTokens(users.UserToEntityUser(*user), projectName, organisationName)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.ServiceTokensToApi(tokens), nil
+}
+
+func (r *queryResolver) AuditLog(ctx context.Context, projectName *string, pagination *model.Pagination, organisation *string) (*model.AuditLog, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	page, err := factory.ApiPaginationToPagination(pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectName == nil {
+		user, err := r.authorize(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		entries, total, err := r.SplitService.GetUserAuditLog(users.UserToEntityUser(*user), page)
+		if err != nil {
+			return nil, err
+		}
+		return factory.AuditLogToApi(entries, total), nil
+	}
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectManage, organisationName, *projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, total, err := r.SplitService.GetProjectAuditLog(users.UserToEntityUser(*user), *projectName, organisationName, page)
+	if err != nil {
+		return nil, err
+	}
+	return factory.AuditLogToApi(entries, total), nil
+}
+
+func (r *queryResolver) RetentionPreview(ctx context.Context, projectName string, policy *model.RetentionPolicyInput, organisation *string) (*model.RetentionPreview, error) {
+	organisationName := factory.ApiOrganisationToName(organisation)
+
+	user, err := r.authorizeProject(ctx, entities.ScopeProjectRead, organisationName, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	current, expired, err := r.SplitService.PreviewRetention(users.UserToEntityUser(*user), projectName, organisationName, factory.ApiRetentionPolicyToPolicy(policy))
+	if err != nil {
+		return nil, err
+	}
+
+	return factory.RetentionPreviewToApi(current, expired), nil
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shelex/split-specs/domain"
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/archive"
+	"github.com/Shelex/split-specs/pkg/ci"
+)
+
+// Command runs subcommand instead of starting server, admin commands work with storage selected by ENV,
+// session command is a client of running server
+func Command(name string, args []string) error {
+	switch name {
+	case "export":
+		return exportCommand(args)
+	case "import":
+		return importCommand(args)
+	case "migrate":
+		return migrateCommand()
+	case "session":
+		return sessionCommand(args)
+	default:
+		return fmt.Errorf("unknown command %s, available commands: export, import, migrate, session", name)
+	}
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	email := flags.String("user", "", "email of project owner")
+	projectName := flags.String("project", "", "name of project to export")
+	organisation := flags.String("organisation", "", "organisation of project, when user has several projects with such name")
+	format := flags.String("format", archive.FormatNDJSON, "archive format: json or ndjson")
+	output := flags.String("out", "", "archive file, stdout by default")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" || *projectName == "" {
+		return fmt.Errorf("export requires -user and -project")
+	}
+
+	if !archive.IsValidFormat(*format) {
+		return archive.ErrUnknownFormat
+	}
+
+	svc, user, err := commandService(*email)
+	if err != nil {
+		return err
+	}
+
+	exported, err := svc.ExportProject(user, *projectName, *organisation)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return archive.Write(w, exported, *format)
+}
+
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	email := flags.String("user", "", "email of user to become project owner")
+	projectName := flags.String("project", "", "new project name, name from archive by default")
+	input := flags.String("in", "", "archive file, stdin by default")
+	withMembers := flags.Bool("members", false, "attach archive members having an account with their roles")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return fmt.Errorf("import requires -user")
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	imported, err := archive.Read(r)
+	if err != nil {
+		return err
+	}
+
+	svc, user, err := commandService(*email)
+	if err != nil {
+		return err
+	}
+
+	name, err := svc.ImportProject(user, imported, *projectName, *withMembers)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported project %s with %d sessions\n", name, len(imported.Sessions))
+	return nil
+}
+
+// migrateCommand updates stored entities created by previous versions
+func migrateCommand() error {
+	db, err := InitDb()
+	if err != nil {
+		return fmt.Errorf("failed to initialize db: %s", err)
+	}
+
+	migrated, err := db.MigrateSessions()
+	if err != nil {
+		return fmt.Errorf("migrated %d sessions before failure: %s", migrated, err)
+	}
+
+	fmt.Printf("migrated %d sessions\n", migrated)
+
+	migrated, err = db.MigrateSpecs()
+	if err != nil {
+		return fmt.Errorf("migrated %d specs before failure: %s", migrated, err)
+	}
+
+	fmt.Printf("migrated %d specs\n", migrated)
+
+	svc := domain.NewSplitService(db)
+
+	migrated, err = svc.MigrateMemberRoles()
+	if err != nil {
+		return fmt.Errorf("migrated %d project members before failure: %s", migrated, err)
+	}
+
+	fmt.Printf("migrated %d project members\n", migrated)
+	return nil
+}
+
+func commandService(email string) (domain.SplitService, entities.User, error) {
+	db, err := InitDb()
+	if err != nil {
+		return domain.SplitService{}, entities.User{}, fmt.Errorf("failed to initialize db: %s", err)
+	}
+
+	user, err := db.GetUserByEmail(email)
+	if err != nil {
+		return domain.SplitService{}, entities.User{}, fmt.Errorf("user %s not found", email)
+	}
+
+	return domain.NewSplitService(db), *user, nil
+}
+
+// labelFlags collects repeated -label key=value flags
+type labelFlags []string
+
+func (l *labelFlags) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *labelFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("label should be formatted as key=value")
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+const addSessionMutation = `mutation ($session: SessionInput!) {
+  addSession(session: $session) {
+    sessionId
+  }
+}`
+
+// sessionCommand creates session for spec files passed as arguments and prints its id,
+// metadata of CI build is detected from environment and attached to session
+func sessionCommand(args []string) error {
+	flags := flag.NewFlagSet("session", flag.ContinueOnError)
+	server := flags.String("server", os.Getenv("SPLIT_SPECS_URL"), "url of split-specs server, SPLIT_SPECS_URL by default")
+	token := flags.String("token", os.Getenv("SPLIT_SPECS_TOKEN"), "api key or access token, SPLIT_SPECS_TOKEN by default")
+	projectName := flags.String("project", "", "project name")
+	organisation := flags.String("organisation", "", "organisation of project")
+	abortAfterFailures := flags.Int("abort-after-failures", 0, "abort session after this amount of failed specs")
+	estimateBy := flags.String("estimate-by", "", "comma separated label keys to restrict estimation history")
+	noCI := flags.Bool("no-ci", false, "do not attach detected CI metadata")
+
+	var labels labelFlags
+	flags.Var(&labels, "label", "session label as key=value, could be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *server == "" || *token == "" || *projectName == "" {
+		return fmt.Errorf("session requires -server, -token and -project")
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("session requires spec files as arguments")
+	}
+
+	session := map[string]interface{}{
+		"projectName": *projectName,
+	}
+
+	specFiles := make([]map[string]string, flags.NArg())
+	for i, file := range flags.Args() {
+		specFiles[i] = map[string]string{"filePath": file}
+	}
+	session["specFiles"] = specFiles
+
+	if *organisation != "" {
+		session["organisation"] = *organisation
+	}
+	if *abortAfterFailures > 0 {
+		session["abortAfterFailures"] = *abortAfterFailures
+	}
+	if *estimateBy != "" {
+		session["estimateBy"] = strings.Split(*estimateBy, ",")
+	}
+
+	inputLabels := make([]map[string]string, len(labels))
+	for i, label := range labels {
+		// label keys cannot contain "=", so value starts after the first one
+		parts := strings.SplitN(label, "=", 2)
+		inputLabels[i] = map[string]string{"key": parts[0], "value": parts[1]}
+	}
+	session["labels"] = inputLabels
+
+	if metadata, ok := ci.Detect(os.Getenv); ok && !*noCI {
+		session["ci"] = map[string]string{
+			"provider":    metadata.Provider,
+			"runId":       metadata.RunID,
+			"commit":      metadata.Commit,
+			"branch":      metadata.Branch,
+			"pullRequest": metadata.PullRequest,
+			"jobUrl":      metadata.JobURL,
+		}
+	}
+
+	var data struct {
+		AddSession struct {
+			SessionID string `json:"sessionId"`
+		} `json:"addSession"`
+	}
+
+	if err := graphqlRequest(*server, *token, addSessionMutation, map[string]interface{}{"session": session}, &data); err != nil {
+		return err
+	}
+
+	fmt.Println(data.AddSession.SessionID)
+	return nil
+}
+
+// graphqlRequest sends query to server and decodes data of response, graphql errors are returned as error
+func graphqlRequest(server string, token string, query string, variables map[string]interface{}, data interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(server, "/")+"/query", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", token)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("server responded with %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to read server response: %s", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%s", result.Errors[0].Message)
+	}
+
+	return json.Unmarshal(result.Data, data)
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/internal/users"
+)
+
+var ErrInvalidResetToken = errors.New("password reset token is invalid or expired")
+
+// ChangePassword sets new password after checking current one and signs user out everywhere
+func (svc *SplitService) ChangePassword(user entities.User, password string, newPassword string) error {
+	dbUser, err := svc.Repository.GetUserByEmail(user.Email)
+	if err != nil || !users.CheckPasswordHash(password, dbUser.Password) {
+		return &users.AccessDeniedError{}
+	}
+
+	hashedPassword, err := users.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Repository.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := svc.revokeTokens(user.ID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditPasswordChange, "", "")
+	return nil
+}
+
+// ResetPassword sets new password with single-use reset token identified by resetID
+// and signs user out everywhere, ip is recorded in audit log as user is not signed in
+func (svc *SplitService) ResetPassword(resetID string, newPassword string, ip string) error {
+	if newPassword == "" {
+		return &users.InvalidEmailOrPassordError{}
+	}
+
+	reset, err := svc.Repository.ConsumePasswordReset(resetID)
+	if err != nil || reset.ExpireAt < time.Now().Unix() {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := users.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.Repository.UpdatePassword(reset.UserID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.DeleteUserPasswordResets(reset.UserID); err != nil {
+		return err
+	}
+
+	if err := svc.revokeTokens(reset.UserID); err != nil {
+		return err
+	}
+
+	user, err := svc.Repository.GetUserByID(reset.UserID)
+	if err != nil {
+		return err
+	}
+	user.IP = ip
+
+	svc.audit(*user, entities.AuditPasswordReset, "", "")
+	return nil
+}
+
+// LogoutEverywhere signs user out everywhere by invalidating issued access and refresh tokens
+func (svc *SplitService) LogoutEverywhere(user entities.User) error {
+	if err := svc.revokeTokens(user.ID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditLogoutEverywhere, "", "")
+	return nil
+}
+
+func (svc *SplitService) revokeTokens(userID string) error {
+	if err := svc.Repository.DeleteUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	return svc.Repository.RevokeUserTokens(userID, time.Now().Unix())
+}
@@ -0,0 +1,187 @@
+package domain
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+const (
+	defaultAnalyticsWindow       = 20
+	defaultSlowestSpecs          = 10
+	defaultRegressionThreshold   = 0.5
+	maxAnalyticsWindow           = 100
+	minRegressionBaselineSamples = 2
+)
+
+func (svc *SplitService) GetProjectAnalytics(projectID string, options entities.AnalyticsOptions) (entities.ProjectAnalytics, error) {
+	options = withAnalyticsDefaults(options)
+
+	sessions, err := svc.Repository.GetProjectLatestSessions(projectID, options.Window, nil)
+	if err != nil {
+		return entities.ProjectAnalytics{}, err
+	}
+
+	if len(sessions) == 0 {
+		return CalculateProjectAnalytics(sessions, nil, options), nil
+	}
+
+	// specs of window sessions are finished after the oldest of them started,
+	// specs of other sessions finished in that time are skipped by aggregation
+	since := sessions[0].Start
+	for _, session := range sessions {
+		if session.Start < since {
+			since = session.Start
+		}
+	}
+
+	specs, err := svc.Repository.GetProjectFinishedSpecs(projectID, since)
+	if err != nil {
+		return entities.ProjectAnalytics{}, err
+	}
+
+	return CalculateProjectAnalytics(sessions, specs, options), nil
+}
+
+func withAnalyticsDefaults(options entities.AnalyticsOptions) entities.AnalyticsOptions {
+	if options.Window <= 0 {
+		options.Window = defaultAnalyticsWindow
+	}
+	if options.Window > maxAnalyticsWindow {
+		options.Window = maxAnalyticsWindow
+	}
+	if options.Slowest <= 0 {
+		options.Slowest = defaultSlowestSpecs
+	}
+	if options.RegressionThreshold <= 0 {
+		options.RegressionThreshold = defaultRegressionThreshold
+	}
+	return options
+}
+
+// CalculateProjectAnalytics aggregates finished sessions and their finished specs.
+// Regression compares duration of the latest run of a spec with average of its previous runs,
+// spec is reported when latest run is slower than baseline by more than threshold (0.5 = 50%).
+func CalculateProjectAnalytics(sessions []*entities.Session, specs []entities.Spec, options entities.AnalyticsOptions) entities.ProjectAnalytics {
+	analytics := entities.ProjectAnalytics{
+		Window:        options.Window,
+		DurationTrend: make([]entities.SessionDuration, 0, len(sessions)),
+		SlowestSpecs:  make([]entities.SpecDurationHistory, 0),
+		Regressions:   make([]entities.SpecRegression, 0),
+	}
+
+	sessionEnds := make(map[string]int64, len(sessions))
+
+	for _, session := range sessions {
+		sessionEnds[session.ID] = session.End
+		if session.Start == 0 {
+			continue
+		}
+		analytics.DurationTrend = append(analytics.DurationTrend, entities.SessionDuration{
+			SessionID: session.ID,
+			Start:     session.Start,
+			End:       session.End,
+			Duration:  session.End - session.Start,
+		})
+	}
+
+	sort.Slice(analytics.DurationTrend, func(i, j int) bool {
+		return analytics.DurationTrend[i].End < analytics.DurationTrend[j].End
+	})
+
+	histories := make(map[string][]entities.SpecRunDuration)
+	passed := 0
+
+	for _, spec := range specs {
+		if _, ok := sessionEnds[spec.SessionID]; !ok {
+			continue
+		}
+		if spec.Passed {
+			passed++
+		}
+		histories[spec.FilePath] = append(histories[spec.FilePath], entities.SpecRunDuration{
+			SessionID: spec.SessionID,
+			End:       spec.End,
+			Duration:  spec.End - spec.Start,
+			Passed:    spec.Passed,
+		})
+	}
+
+	runs := 0
+	for filePath, history := range histories {
+		sort.Slice(history, func(i, j int) bool {
+			return history[i].End < history[j].End
+		})
+		runs += len(history)
+
+		analytics.SlowestSpecs = append(analytics.SlowestSpecs, entities.SpecDurationHistory{
+			FilePath:        filePath,
+			AverageDuration: averageDuration(history),
+			History:         history,
+		})
+
+		if regression, ok := detectRegression(filePath, history, options.RegressionThreshold); ok {
+			analytics.Regressions = append(analytics.Regressions, regression)
+		}
+	}
+
+	if runs > 0 {
+		analytics.PassRate = float64(passed) / float64(runs)
+	}
+
+	sort.Slice(analytics.SlowestSpecs, func(i, j int) bool {
+		if analytics.SlowestSpecs[i].AverageDuration == analytics.SlowestSpecs[j].AverageDuration {
+			return analytics.SlowestSpecs[i].FilePath < analytics.SlowestSpecs[j].FilePath
+		}
+		return analytics.SlowestSpecs[i].AverageDuration > analytics.SlowestSpecs[j].AverageDuration
+	})
+
+	if len(analytics.SlowestSpecs) > options.Slowest {
+		analytics.SlowestSpecs = analytics.SlowestSpecs[:options.Slowest]
+	}
+
+	sort.Slice(analytics.Regressions, func(i, j int) bool {
+		return analytics.Regressions[i].Ratio > analytics.Regressions[j].Ratio
+	})
+
+	return analytics
+}
+
+func detectRegression(filePath string, history []entities.SpecRunDuration, threshold float64) (entities.SpecRegression, bool) {
+	if len(history) < minRegressionBaselineSamples+1 {
+		return entities.SpecRegression{}, false
+	}
+
+	latest := history[len(history)-1]
+	baseline := averageDuration(history[:len(history)-1])
+
+	if baseline == 0 {
+		return entities.SpecRegression{}, false
+	}
+
+	ratio := float64(latest.Duration) / float64(baseline)
+
+	if ratio <= 1+threshold {
+		return entities.SpecRegression{}, false
+	}
+
+	return entities.SpecRegression{
+		FilePath:         filePath,
+		BaselineDuration: baseline,
+		LatestDuration:   latest.Duration,
+		Ratio:            ratio,
+	}, true
+}
+
+func averageDuration(history []entities.SpecRunDuration) int64 {
+	if len(history) == 0 {
+		return 0
+	}
+
+	var total int64
+	for _, run := range history {
+		total += run.Duration
+	}
+	return int64(math.Round(float64(total) / float64(len(history))))
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+func TestCalculateProjectAnalytics(t *testing.T) {
+	options := entities.AnalyticsOptions{Window: 3, Slowest: 1, RegressionThreshold: 0.5}
+
+	finished := []*entities.Session{
+		{ID: "s3", Start: 200, End: 300},
+		{ID: "s2", Start: 100, End: 200},
+		{ID: "s1", Start: 10, End: 100},
+	}
+
+	tests := []struct {
+		name     string
+		sessions []*entities.Session
+		specs    []entities.Spec
+		expected entities.ProjectAnalytics
+	}{
+		{
+			name: "no sessions",
+			expected: entities.ProjectAnalytics{
+				Window:        3,
+				DurationTrend: []entities.SessionDuration{},
+				SlowestSpecs:  []entities.SpecDurationHistory{},
+				Regressions:   []entities.SpecRegression{},
+			},
+		},
+		{
+			name:     "sessions without start and specs",
+			sessions: []*entities.Session{{ID: "s1", End: 100}},
+			expected: entities.ProjectAnalytics{
+				Window:        3,
+				DurationTrend: []entities.SessionDuration{},
+				SlowestSpecs:  []entities.SpecDurationHistory{},
+				Regressions:   []entities.SpecRegression{},
+			},
+		},
+		{
+			name:     "slowest specs, regressions and pass rate",
+			sessions: finished,
+			specs: []entities.Spec{
+				{SessionID: "s3", FilePath: "a", Start: 200, End: 220, Passed: true},
+				{SessionID: "s1", FilePath: "a", Start: 10, End: 20, Passed: true},
+				{SessionID: "s2", FilePath: "a", Start: 100, End: 110, Passed: true},
+				{SessionID: "s1", FilePath: "b", Start: 10, End: 40, Passed: true},
+				{SessionID: "s2", FilePath: "b", Start: 100, End: 130, Passed: true},
+				{SessionID: "s3", FilePath: "b", Start: 200, End: 230},
+				{SessionID: "outside", FilePath: "c", Start: 0, End: 500, Passed: true},
+			},
+			expected: entities.ProjectAnalytics{
+				Window:   3,
+				PassRate: 5.0 / 6.0,
+				DurationTrend: []entities.SessionDuration{
+					{SessionID: "s1", Start: 10, End: 100, Duration: 90},
+					{SessionID: "s2", Start: 100, End: 200, Duration: 100},
+					{SessionID: "s3", Start: 200, End: 300, Duration: 100},
+				},
+				SlowestSpecs: []entities.SpecDurationHistory{
+					{
+						FilePath:        "b",
+						AverageDuration: 30,
+						History: []entities.SpecRunDuration{
+							{SessionID: "s1", End: 40, Duration: 30, Passed: true},
+							{SessionID: "s2", End: 130, Duration: 30, Passed: true},
+							{SessionID: "s3", End: 230, Duration: 30},
+						},
+					},
+				},
+				Regressions: []entities.SpecRegression{
+					{FilePath: "a", BaselineDuration: 10, LatestDuration: 20, Ratio: 2},
+				},
+			},
+		},
+		{
+			name:     "slowdown within threshold and too few runs",
+			sessions: finished,
+			specs: []entities.Spec{
+				{SessionID: "s1", FilePath: "a", Start: 10, End: 20, Passed: true},
+				{SessionID: "s2", FilePath: "a", Start: 100, End: 110, Passed: true},
+				{SessionID: "s3", FilePath: "a", Start: 200, End: 215, Passed: true},
+				{SessionID: "s2", FilePath: "b", Start: 100, End: 110, Passed: true},
+				{SessionID: "s3", FilePath: "b", Start: 200, End: 300, Passed: true},
+			},
+			expected: entities.ProjectAnalytics{
+				Window:   3,
+				PassRate: 1,
+				DurationTrend: []entities.SessionDuration{
+					{SessionID: "s1", Start: 10, End: 100, Duration: 90},
+					{SessionID: "s2", Start: 100, End: 200, Duration: 100},
+					{SessionID: "s3", Start: 200, End: 300, Duration: 100},
+				},
+				SlowestSpecs: []entities.SpecDurationHistory{
+					{
+						FilePath:        "b",
+						AverageDuration: 55,
+						History: []entities.SpecRunDuration{
+							{SessionID: "s2", End: 110, Duration: 10, Passed: true},
+							{SessionID: "s3", End: 300, Duration: 100, Passed: true},
+						},
+					},
+				},
+				Regressions: []entities.SpecRegression{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analytics := CalculateProjectAnalytics(test.sessions, test.specs, options)
+			if !reflect.DeepEqual(analytics, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, analytics)
+			}
+		})
+	}
+}
+
+func TestProjectAnalyticsWindow(t *testing.T) {
+	repo, err := storage.NewInMemStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewSplitService(repo)
+
+	sessions := []struct {
+		session entities.Session
+		spec    entities.Spec
+	}{
+		{
+			session: entities.Session{ID: "old", ProjectID: "p1", Start: 0, End: 100},
+			spec:    entities.Spec{FilePath: "old.spec.js", Start: 0, End: 100, Passed: true},
+		},
+		// finished after the latest session started, but is not in the window
+		{
+			session: entities.Session{ID: "long", ProjectID: "p1", Start: 50, End: 260},
+			spec:    entities.Spec{FilePath: "long.spec.js", Start: 50, End: 250, Passed: true},
+		},
+		{
+			session: entities.Session{ID: "latest", ProjectID: "p1", Start: 200, End: 300},
+			spec:    entities.Spec{FilePath: "latest.spec.js", Start: 200, End: 300, Passed: true},
+		},
+		{
+			session: entities.Session{ID: "other", ProjectID: "p2", Start: 200, End: 400},
+			spec:    entities.Spec{FilePath: "latest.spec.js", Start: 200, End: 400},
+		},
+	}
+
+	for _, item := range sessions {
+		if _, err := repo.CreateSession(item.session, []entities.Spec{item.spec}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analytics, err := svc.GetProjectAnalytics("p1", entities.AnalyticsOptions{Window: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(analytics.DurationTrend) != 1 || analytics.DurationTrend[0].SessionID != "latest" {
+		t.Fatalf("expected only latest session in duration trend, got %+v", analytics.DurationTrend)
+	}
+	if len(analytics.SlowestSpecs) != 1 || analytics.SlowestSpecs[0].FilePath != "latest.spec.js" {
+		t.Fatalf("expected only spec of latest session, got %+v", analytics.SlowestSpecs)
+	}
+	if analytics.PassRate != 1 {
+		t.Fatalf("expected pass rate of latest session only, got %f", analytics.PassRate)
+	}
+}
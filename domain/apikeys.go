@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/Shelex/split-specs/entities"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// AddApiKey creates api key restricted to scopes and projects, default scopes are used when not specified
+func (svc *SplitService) AddApiKey(user entities.User, name string, expireAt int64, scopes []string, projectNames []string, organisationName string) (entities.ApiKey, error) {
+	keyScopes, keyProjects, err := svc.apiKeyAccess(user, scopes, projectNames, organisationName)
+	if err != nil {
+		return entities.ApiKey{}, err
+	}
+
+	id, _ := gonanoid.New()
+
+	apiKey := entities.ApiKey{
+		ID:       id,
+		UserID:   user.ID,
+		Name:     name,
+		ExpireAt: expireAt,
+		Scopes:   keyScopes,
+		Projects: keyProjects,
+	}
+
+	if err := svc.Repository.CreateApiKey(user.ID, apiKey); err != nil {
+		return entities.ApiKey{}, err
+	}
+
+	svc.audit(user, entities.AuditApiKeyCreate, "", name)
+	return apiKey, nil
+}
+
+// DeleteApiKey removes api key of user, tokens issued for it are rejected since then
+func (svc *SplitService) DeleteApiKey(user entities.User, keyID string) error {
+	if err := svc.Repository.DeleteApiKey(user.ID, keyID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditApiKeyDelete, "", keyID)
+	return nil
+}
+
+// apiKeyAccess validates scopes and projects requested for api key
+// and returns scopes (default ones when not specified) and ids of projects
+func (svc *SplitService) apiKeyAccess(user entities.User, scopes []string, projectNames []string, organisationName string) ([]string, []string, error) {
+	if len(scopes) == 0 {
+		scopes = entities.DefaultScopes
+	}
+
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return nil, nil, fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+
+	projectIDs := make([]string, len(projectNames))
+
+	for index, projectName := range projectNames {
+		projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("project %s not found", projectName)
+		}
+		projectIDs[index] = projectID
+	}
+
+	return scopes, projectIDs, nil
+}
+
+// GetProjectNames returns names of existing projects by their ids
+func (svc *SplitService) GetProjectNames(projectIDs []string) []string {
+	names := make([]string, 0, len(projectIDs))
+
+	for _, id := range projectIDs {
+		project, err := svc.Repository.GetProjectByID(id)
+		if err != nil || project.DeletedAt != 0 {
+			continue
+		}
+		names = append(names, project.Name)
+	}
+	return names
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range entities.AllScopes {
+		if known == scope {
+			return true
+		}
+	}
+	return false
+}
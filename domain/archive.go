@@ -0,0 +1,273 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/archive"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// ExportProject copies project with sessions, specs and members to archive, deleted sessions are not exported
+func (svc *SplitService) ExportProject(user entities.User, projectName string, organisationName string) (archive.Archive, error) {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return archive.Archive{}, err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return archive.Archive{}, err
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return archive.Archive{}, err
+	}
+
+	exported := archive.Archive{
+		Version:    archive.Version,
+		ExportedAt: time.Now().Unix(),
+		Project: archive.Project{
+			Name:                project.Name,
+			RetentionKeepLast:   project.RetentionKeepLast,
+			RetentionMaxAgeDays: project.RetentionMaxAgeDays,
+			EstimationKeys:      project.EstimationKeys,
+		},
+		Members:  make([]archive.Member, 0),
+		Sessions: make([]archive.Session, 0),
+	}
+
+	if source, ok := svc.visibleEstimationSource(user.ID, project); ok {
+		exported.Project.EstimationSource = source.Name
+	}
+
+	if project.OrganisationID != "" {
+		if organisation, err := svc.Repository.GetOrganisationByID(project.OrganisationID); err == nil {
+			exported.Project.Organisation = organisation.Name
+		}
+	}
+
+	members, err := svc.Repository.GetProjectMembers(projectID)
+	if err != nil {
+		return archive.Archive{}, err
+	}
+
+	for _, member := range members {
+		memberUser, err := svc.Repository.GetUserByID(member.UserID)
+		if err != nil {
+			continue
+		}
+		exported.Members = append(exported.Members, archive.Member{
+			Email: memberUser.Email,
+			Role:  memberRole(member),
+		})
+	}
+
+	baselines, err := svc.GetBaseline(projectID)
+	if err != nil {
+		return archive.Archive{}, err
+	}
+
+	for _, baseline := range baselines {
+		exported.Baselines = append(exported.Baselines, archive.Baseline{
+			FilePath: baseline.FilePath,
+			Duration: baseline.Duration,
+		})
+	}
+
+	sessions, _, err := svc.Repository.GetProjectSessions(projectID, nil, nil)
+	if err != nil {
+		return archive.Archive{}, err
+	}
+
+	for _, session := range sessions {
+		exported.Sessions = append(exported.Sessions, sessionToArchive(session))
+	}
+
+	svc.audit(user, entities.AuditProjectExport, projectID, projectName)
+	return exported, nil
+}
+
+// ImportProject recreates archived project for user with new ids, project could be renamed with projectName.
+// User becomes project owner, organisation is not restored. Archive members are attached by email only with withMembers,
+// which is available to instance administrators, as archive is not trusted to grant access to other accounts
+func (svc *SplitService) ImportProject(user entities.User, imported archive.Archive, projectName string, withMembers bool) (string, error) {
+	if projectName == "" {
+		projectName = imported.Project.Name
+	}
+
+	if projectName == "" {
+		return "", fmt.Errorf("project name cannot be empty")
+	}
+
+	if svc.hasProjectNamed(user.ID, projectName) {
+		return "", fmt.Errorf("project %s already exists", projectName)
+	}
+
+	var members []entities.UserProject
+	if withMembers {
+		var err error
+		members, err = svc.importMembers(user, imported.Members, projectName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	projectID, err := svc.AddProject(user.ID, projectName, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := svc.Repository.SetProjectRetention(projectID, entities.RetentionPolicy{
+		KeepLast:   imported.Project.RetentionKeepLast,
+		MaxAgeDays: imported.Project.RetentionMaxAgeDays,
+	}); err != nil {
+		return "", err
+	}
+
+	if imported.Project.EstimationSource != "" {
+		if sourceID, err := svc.GetProjectIDByName(user.ID, "", imported.Project.EstimationSource); err == nil && sourceID != projectID {
+			if err := svc.Repository.SetProjectEstimationSource(projectID, sourceID, user.ID); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// keys are recorded for estimation source as well, so it is done after source is set
+	if err := svc.recordEstimationKeys(projectID, imported.Project.EstimationKeys); err != nil {
+		return "", err
+	}
+
+	if len(imported.Baselines) > 0 {
+		baselines := make([]entities.SpecBaseline, len(imported.Baselines))
+		for index, baseline := range imported.Baselines {
+			baselines[index] = entities.SpecBaseline{
+				ProjectID: projectID,
+				FilePath:  baseline.FilePath,
+				Duration:  baseline.Duration,
+			}
+		}
+		if err := svc.Repository.SetProjectBaseline(projectID, baselines); err != nil {
+			return "", err
+		}
+	}
+
+	for _, member := range members {
+		if err := svc.Repository.AttachProjectToUser(member.UserID, projectID, member.Role); err != nil {
+			return "", err
+		}
+	}
+
+	for _, session := range imported.Sessions {
+		id, _ := gonanoid.New()
+
+		if _, err := svc.Repository.CreateSession(entities.Session{
+			ID:                 id,
+			ProjectID:          projectID,
+			Start:              session.Start,
+			End:                session.End,
+			AbortAfterFailures: session.AbortAfterFailures,
+			AbortedBy:          session.AbortedBy,
+			Labels:             archiveToLabels(session.Labels),
+		}, archiveToSpecs(session.Specs)); err != nil {
+			return "", err
+		}
+	}
+
+	svc.audit(user, entities.AuditProjectImport, projectID, fmt.Sprintf("%s with %d sessions", projectName, len(imported.Sessions)))
+	return projectName, nil
+}
+
+// importMembers resolves archive members having an account, the same as InviteUserToProject
+// member should not have other project with such name, it is checked before project is created
+func (svc *SplitService) importMembers(user entities.User, archived []archive.Member, projectName string) ([]entities.UserProject, error) {
+	var members []entities.UserProject
+
+	for _, member := range archived {
+		if !IsValidRole(member.Role) {
+			continue
+		}
+		memberUser, err := svc.Repository.GetUserByEmail(member.Email)
+		if err != nil || memberUser.ID == user.ID {
+			continue
+		}
+		if svc.hasProjectNamed(memberUser.ID, projectName) {
+			return nil, fmt.Errorf("member %s already has project with such name", member.Email)
+		}
+		members = append(members, entities.UserProject{
+			UserID: memberUser.ID,
+			Role:   member.Role,
+		})
+	}
+	return members, nil
+}
+
+func sessionToArchive(session entities.SessionWithSpecs) archive.Session {
+	specs := make([]archive.Spec, len(session.Specs))
+	for index, spec := range session.Specs {
+		specs[index] = archive.Spec{
+			FilePath:          spec.FilePath,
+			Tests:             spec.Tests,
+			EstimatedDuration: spec.EstimatedDuration,
+			Start:             spec.Start,
+			End:               spec.End,
+			Passed:            spec.Passed,
+			Skipped:           spec.Skipped,
+			AssignedTo:        spec.AssignedTo,
+		}
+	}
+
+	return archive.Session{
+		ID:                 session.ID,
+		Start:              session.Start,
+		End:                session.End,
+		AbortAfterFailures: session.AbortAfterFailures,
+		AbortedBy:          session.AbortedBy,
+		Labels:             labelsToArchive(session.Labels),
+		Specs:              specs,
+	}
+}
+
+func labelsToArchive(labels []entities.Label) []archive.Label {
+	archived := make([]archive.Label, len(labels))
+	for index, label := range labels {
+		archived[index] = archive.Label{
+			Key:   label.Key,
+			Value: label.Value,
+		}
+	}
+	return archived
+}
+
+func archiveToLabels(archived []archive.Label) []entities.Label {
+	if len(archived) == 0 {
+		return nil
+	}
+
+	labels := make([]entities.Label, len(archived))
+	for index, label := range archived {
+		labels[index] = entities.Label{
+			Key:   label.Key,
+			Value: label.Value,
+		}
+	}
+	return labels
+}
+
+func archiveToSpecs(archived []archive.Spec) []entities.Spec {
+	specs := make([]entities.Spec, len(archived))
+	for index, spec := range archived {
+		specs[index] = entities.Spec{
+			FilePath:          spec.FilePath,
+			Tests:             spec.Tests,
+			EstimatedDuration: spec.EstimatedDuration,
+			Start:             spec.Start,
+			End:               spec.End,
+			Passed:            spec.Passed,
+			Skipped:           spec.Skipped,
+			AssignedTo:        spec.AssignedTo,
+		}
+	}
+	return specs
+}
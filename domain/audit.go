@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// auditFailures counts audit log entries which were not written, actions themselves are already made
+// and are not failed, so the counter is published to alert on gaps in audit log
+var auditFailures = expvar.NewInt("auditFailures")
+
+// audit appends entry to audit log, action is already made so failure to record it is logged and counted
+func (svc *SplitService) audit(actor entities.User, action string, projectID string, target string) {
+	id, _ := gonanoid.New()
+
+	if err := svc.Repository.CreateAuditEntry(entities.AuditEntry{
+		ID:         id,
+		ProjectID:  projectID,
+		ActorID:    actor.ID,
+		ActorEmail: actor.Email,
+		Action:     action,
+		Target:     target,
+		Timestamp:  time.Now().Unix(),
+		IP:         actor.IP,
+	}); err != nil {
+		auditFailures.Add(1)
+		log.Printf("failed to write audit log entry %s by %s: %s", action, actor.ID, err)
+	}
+}
+
+// GetProjectAuditLog returns project audit log for project owners
+func (svc *SplitService) GetProjectAuditLog(user entities.User, projectName string, organisationName string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return nil, 0, err
+	}
+
+	return svc.Repository.GetProjectAuditLog(projectID, pagination)
+}
+
+// GetUserAuditLog returns actions made by user
+func (svc *SplitService) GetUserAuditLog(user entities.User, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	return svc.Repository.GetUserAuditLog(user.ID, pagination)
+}
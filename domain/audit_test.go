@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+// failingAuditStorage stores everything except audit log entries
+type failingAuditStorage struct {
+	storage.Storage
+}
+
+func (failingAuditStorage) CreateAuditEntry(entry entities.AuditEntry) error {
+	return errors.New("audit log is unavailable")
+}
+
+func failingAuditService(t *testing.T) SplitService {
+	repo, err := storage.NewInMemStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSplitService(failingAuditStorage{repo})
+}
+
+func TestActionsSucceedWhenAuditFails(t *testing.T) {
+	svc := failingAuditService(t)
+	user := entities.User{ID: "owner", Email: "owner@example.com"}
+	failuresBefore := auditFailures.Value()
+
+	session := entities.Session{ID: "session-1"}
+	specs := []entities.Spec{{FilePath: "a.spec.js"}}
+	if err := svc.AddSession(user, "web", "", session, specs, nil); err != nil {
+		t.Fatalf("add session: %s", err)
+	}
+	if _, err := svc.Repository.GetSession("session-1"); err != nil {
+		t.Fatalf("session is not stored: %s", err)
+	}
+
+	apiKey, err := svc.AddApiKey(user, "ci", 0, nil, nil, "")
+	if err != nil {
+		t.Fatalf("add api key: %s", err)
+	}
+	if apiKey.ID == "" {
+		t.Fatal("api key is not returned")
+	}
+
+	token, err := svc.AddServiceToken(user, "web", "", "ci", 0, nil)
+	if err != nil {
+		t.Fatalf("add service token: %s", err)
+	}
+	if token.ID == "" {
+		t.Fatal("service token is not returned")
+	}
+
+	if err := svc.CreateOrganisation(user, "acme"); err != nil {
+		t.Fatalf("create organisation: %s", err)
+	}
+
+	// project creation, session, api key, service token and organisation
+	if failed := auditFailures.Value() - failuresBefore; failed != 5 {
+		t.Fatalf("expected 5 counted audit failures, got %d", failed)
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/timing"
+)
+
+// ImportBaseline replaces duration baseline of project with durations from timing file (JUnit XML or file,duration csv),
+// project is created when it does not exist yet, so estimates are available for the very first session
+func (svc *SplitService) ImportBaseline(user entities.User, projectName string, organisationName string, content string) ([]entities.SpecBaseline, error) {
+	durations, err := timing.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		if _, isService := svc.servicePrincipalProject(user.ID); isService || !isNotFound(err) {
+			return nil, err
+		}
+		projectID, err = svc.AddProject(user.ID, projectName, "")
+		if err != nil {
+			return nil, err
+		}
+		svc.audit(user, entities.AuditProjectCreate, projectID, projectName)
+	} else if err := svc.authorize(user.ID, projectID, entities.RoleMaintainer); err != nil {
+		return nil, err
+	}
+
+	baselines := make([]entities.SpecBaseline, 0, len(durations))
+	for filePath, seconds := range durations {
+		baselines = append(baselines, entities.SpecBaseline{
+			ProjectID: projectID,
+			FilePath:  filePath,
+			Duration:  int64(math.Round(seconds)),
+		})
+	}
+
+	sort.Slice(baselines, func(i, j int) bool {
+		return baselines[i].FilePath < baselines[j].FilePath
+	})
+
+	if err := svc.Repository.SetProjectBaseline(projectID, baselines); err != nil {
+		return nil, err
+	}
+
+	svc.audit(user, entities.AuditProjectBaseline, projectID, fmt.Sprintf("%d files", len(baselines)))
+	return baselines, nil
+}
+
+// GetBaseline returns imported duration baseline of project
+func (svc *SplitService) GetBaseline(projectID string) ([]entities.SpecBaseline, error) {
+	baselines, err := svc.Repository.GetProjectBaseline(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(baselines, func(i, j int) bool {
+		return baselines[i].FilePath < baselines[j].FilePath
+	})
+	return baselines, nil
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+var ErrProjectDeleted = errors.New("project is deleted, restore it to continue")
+var ErrNotDeleted = errors.New("not deleted")
+
+// getSession returns session unless it is soft deleted
+func (svc *SplitService) getSession(sessionID string) (entities.Session, error) {
+	session, err := svc.Repository.GetSession(sessionID)
+	if err != nil || session.DeletedAt != 0 {
+		return entities.Session{}, storage.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (svc *SplitService) GetSession(user entities.User, sessionID string) (entities.SessionWithSpecs, error) {
+	session, err := svc.Repository.GetSessionWithSpecs(sessionID)
+	if err != nil || session.DeletedAt != 0 {
+		return entities.SessionWithSpecs{}, storage.ErrSessionNotFound
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleViewer); err != nil {
+		return entities.SessionWithSpecs{}, storage.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (svc *SplitService) RestoreSession(user entities.User, sessionID string) error {
+	session, err := svc.Repository.GetSession(sessionID)
+	if err != nil {
+		return storage.ErrSessionNotFound
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleMaintainer); err != nil {
+		return err
+	}
+
+	if session.DeletedAt == 0 {
+		return ErrNotDeleted
+	}
+
+	if err := svc.Repository.SetSessionDeleted(sessionID, 0); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditSessionRestore, session.ProjectID, sessionID)
+	return nil
+}
+
+func (svc *SplitService) RestoreProject(user entities.User, projectName string, organisationName string) error {
+	projectID, err := svc.FindProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	// authorize rejects deleted projects, so only role is checked
+	role, err := svc.projectRole(user.ID, projectID)
+	if err != nil {
+		return err
+	}
+
+	if roleRanks[role] < roleRanks[entities.RoleOwner] {
+		return ErrPermissionDenied
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+
+	if project.DeletedAt == 0 {
+		return ErrNotDeleted
+	}
+
+	if err := svc.Repository.SetProjectDeleted(projectID, 0); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditProjectRestore, projectID, projectName)
+	return nil
+}
+
+// PurgeDeleted permanently removes sessions and projects deleted before the given moment (unix seconds)
+func (svc *SplitService) PurgeDeleted(before int64) (int, error) {
+	purged := 0
+
+	sessions, err := svc.Repository.GetDeletedSessions(before)
+	if err != nil {
+		return purged, err
+	}
+
+	for _, session := range sessions {
+		if err := svc.Repository.DeleteSession(session.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	projects, err := svc.Repository.GetDeletedProjects(before)
+	if err != nil {
+		return purged, err
+	}
+
+	for _, project := range projects {
+		if err := svc.Repository.DeleteProject(project.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunPurge purges deleted sessions and projects older than retention every interval
+func (svc *SplitService) RunPurge(retention time.Duration, interval time.Duration) {
+	for {
+		purged, err := svc.PurgeDeleted(time.Now().Add(-retention).Unix())
+		if err != nil {
+			log.Printf("failed to purge deleted items: %s", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted sessions and projects", purged)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+const (
+	maxLabels           = 20
+	maxLabelKeyLength   = 100
+	maxLabelValueLength = 500
+	// maxLabelFilters is limited by composite indexes of sessions
+	maxLabelFilters = 2
+)
+
+// ValidateLabels checks that labels have keys without "=" and reserved ci prefix and keys are unique
+func ValidateLabels(labels []entities.Label) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("session could have up to %d labels", maxLabels)
+	}
+
+	keys := make(map[string]bool, len(labels))
+
+	for _, label := range labels {
+		if label.Key == "" {
+			return fmt.Errorf("label key cannot be empty")
+		}
+		if strings.Contains(label.Key, "=") {
+			return fmt.Errorf("label key %q cannot contain \"=\"", label.Key)
+		}
+		if strings.HasPrefix(label.Key, entities.LabelCIPrefix) {
+			return fmt.Errorf("label key %q is reserved for ci metadata", label.Key)
+		}
+		if len(label.Key) > maxLabelKeyLength || len(label.Value) > maxLabelValueLength {
+			return fmt.Errorf("label %q is too long", label.Key)
+		}
+		if keys[label.Key] {
+			return fmt.Errorf("label %q is duplicated", label.Key)
+		}
+		keys[label.Key] = true
+	}
+	return nil
+}
+
+// ValidateLabelFilters checks labels used to filter sessions
+func ValidateLabelFilters(labels []entities.Label) error {
+	if len(labels) > maxLabelFilters {
+		return fmt.Errorf("sessions could be filtered by up to %d labels", maxLabelFilters)
+	}
+	return nil
+}
+
+// GetProjectSessions returns page of project sessions having all labels
+func (svc *SplitService) GetProjectSessions(projectID string, labels []entities.Label, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
+	if err := ValidateLabelFilters(labels); err != nil {
+		return nil, 0, err
+	}
+	return svc.Repository.GetProjectSessions(projectID, labels, pagination)
+}
+
+// recordEstimationKeys remembers label keys used to estimate project by its own history and history of its source,
+// so retention keeps latest sessions needed for estimation for each combination of their values
+func (svc *SplitService) recordEstimationKeys(projectID string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+
+	projects := []*entities.Project{project}
+	if source, ok := svc.estimationSourceProject(project); ok {
+		projects = append(projects, source)
+	}
+
+	for _, project := range projects {
+		if hasAllKeys(project.EstimationKeys, keys) {
+			continue
+		}
+		if err := svc.Repository.AddProjectEstimationKeys(project.ID, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasAllKeys(existing []string, keys []string) bool {
+	for _, key := range keys {
+		found := false
+		for _, existingKey := range existing {
+			if existingKey == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// estimationGroup returns values of estimation keys in session labels,
+// sessions of the same group match the same estimateBy filters
+func estimationGroup(labels []entities.Label, keys []string) string {
+	group := make([]string, len(keys))
+
+	for index, key := range keys {
+		// key without value marks absent label, as label keys cannot contain "="
+		group[index] = key
+		for _, label := range labels {
+			if label.Key == key {
+				group[index] = label.Index()
+				break
+			}
+		}
+	}
+	return strings.Join(group, "\n")
+}
+
+// selectLabels returns labels of session with given keys, every key should be present in session labels
+func selectLabels(labels []entities.Label, keys []string) ([]entities.Label, error) {
+	if len(keys) > maxLabelFilters {
+		return nil, fmt.Errorf("sessions could be estimated by up to %d labels", maxLabelFilters)
+	}
+
+	selected := make([]entities.Label, 0, len(keys))
+
+	for _, key := range keys {
+		found := false
+		for _, label := range labels {
+			if label.Key == key {
+				selected = append(selected, label)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("session has no label %q to estimate by", key)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,329 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+var ErrLastOwner = errors.New("project should have at least one owner")
+var ErrOrganisationMember = errors.New("user has access to project through organisation, manage this user in organisation")
+
+var roleRanks = map[string]int{
+	entities.RoleViewer:     1,
+	entities.RoleMaintainer: 2,
+	entities.RoleOwner:      3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// projectRole returns role of the user in project,
+// user could be a member of project itself or of organisation owning the project,
+// in that case the highest role is used
+func (svc *SplitService) projectRole(userID string, projectID string) (string, error) {
+	// service principal is able to run sessions of its own project only
+	if serviceProjectID, ok := svc.servicePrincipalProject(userID); ok {
+		if serviceProjectID != projectID {
+			return "", storage.ErrProjectNotFound
+		}
+		return entities.RoleMaintainer, nil
+	}
+
+	roles, err := svc.projectRoles(projectID)
+	if err != nil {
+		return "", err
+	}
+
+	role, ok := roles[userID]
+	if !ok {
+		return "", storage.ErrProjectNotFound
+	}
+	return role, nil
+}
+
+// projectRoles returns roles of all users having access to project by user id,
+// links created before roles were introduced have no role and are treated as maintainers
+// until migrate command assigns their roles
+func (svc *SplitService) projectRoles(projectID string) (map[string]string, error) {
+	members, err := svc.Repository.GetProjectMembers(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]string, len(members))
+
+	for _, member := range members {
+		roles[member.UserID] = memberRole(member)
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if project.OrganisationID == "" {
+		return roles, nil
+	}
+
+	organisationMembers, err := svc.Repository.GetOrganisationMembers(project.OrganisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range organisationMembers {
+		if roleRanks[member.Role] > roleRanks[roles[member.UserID]] {
+			roles[member.UserID] = member.Role
+		}
+	}
+
+	return roles, nil
+}
+
+func (svc *SplitService) authorize(userID string, projectID string, required string) error {
+	role, err := svc.projectRole(userID, projectID)
+	if err != nil {
+		return err
+	}
+
+	if roleRanks[role] < roleRanks[required] {
+		return ErrPermissionDenied
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+
+	if project.DeletedAt != 0 {
+		return ErrProjectDeleted
+	}
+	return nil
+}
+
+func memberRole(member entities.UserProject) string {
+	if member.Role == "" {
+		return entities.RoleMaintainer
+	}
+	return member.Role
+}
+
+func (svc *SplitService) GetProjectMembers(projectID string) ([]entities.ProjectMember, error) {
+	roles, err := svc.projectRoles(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.toProjectMembers(roles)
+}
+
+func (svc *SplitService) toProjectMembers(roles map[string]string) ([]entities.ProjectMember, error) {
+	members := make([]entities.ProjectMember, 0, len(roles))
+
+	for userID, role := range roles {
+		user, err := svc.Repository.GetUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, entities.ProjectMember{
+			UserID: userID,
+			Email:  user.Email,
+			Role:   role,
+		})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Email < members[j].Email
+	})
+
+	return members, nil
+}
+
+func (svc *SplitService) ChangeMemberRole(user entities.User, projectName string, organisationName string, email string, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role %s", role)
+	}
+
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	member, err := svc.Repository.GetUserByEmail(email)
+	if err != nil {
+		return storage.ErrMemberNotFound
+	}
+
+	currentRole, err := svc.directRole(member.ID, projectID)
+	if err != nil {
+		return err
+	}
+
+	if currentRole == entities.RoleOwner && role != entities.RoleOwner {
+		owners, err := svc.countOwners(projectID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return ErrLastOwner
+		}
+	}
+
+	if err := svc.Repository.SetProjectMemberRole(member.ID, projectID, role); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditMemberRoleChange, projectID, email+" to "+role)
+	return nil
+}
+
+func (svc *SplitService) RemoveMember(user entities.User, projectName string, organisationName string, email string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	member, err := svc.Repository.GetUserByEmail(email)
+	if err != nil {
+		return storage.ErrMemberNotFound
+	}
+
+	if err := svc.unlinkMember(member.ID, projectID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditMemberRemove, projectID, email)
+	return nil
+}
+
+func (svc *SplitService) LeaveProject(user entities.User, projectName string, organisationName string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.unlinkMember(user.ID, projectID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditMemberLeave, projectID, user.Email)
+	return nil
+}
+
+// unlinkMember removes user from project, last owner could not be removed
+// until ownership is transferred to other member or project is deleted
+func (svc *SplitService) unlinkMember(userID string, projectID string) error {
+	role, err := svc.directRole(userID, projectID)
+	if err != nil {
+		return err
+	}
+
+	if role == entities.RoleOwner {
+		owners, err := svc.countOwners(projectID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return ErrLastOwner
+		}
+	}
+
+	return svc.Repository.UnlinkProjectFromUser(userID, projectID)
+}
+
+// directRole returns role of user linked to project itself,
+// members having access only through organisation are managed in organisation
+func (svc *SplitService) directRole(userID string, projectID string) (string, error) {
+	members, err := svc.Repository.GetProjectMembers(projectID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, member := range members {
+		if member.UserID == userID {
+			return memberRole(member), nil
+		}
+	}
+
+	if _, err := svc.projectRole(userID, projectID); err == nil {
+		return "", ErrOrganisationMember
+	}
+	return "", storage.ErrMemberNotFound
+}
+
+// countOwners returns number of owners linked to project itself,
+// organisation owners are not counted as they could leave organisation at any time
+func (svc *SplitService) countOwners(projectID string) (int, error) {
+	members, err := svc.Repository.GetProjectMembers(projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	owners := 0
+	for _, member := range members {
+		if memberRole(member) == entities.RoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+// MigrateMemberRoles assigns roles to project links created before roles were introduced,
+// project creator and link creation time are not stored, so in project without direct owner
+// legacy link with the lowest id becomes owner and the rest become maintainers.
+// Returns number of updated links
+func (svc *SplitService) MigrateMemberRoles() (int, error) {
+	legacy, err := svc.Repository.GetLegacyProjectMembers()
+	if err != nil {
+		return 0, err
+	}
+
+	byProject := make(map[string][]entities.UserProject)
+	projectIDs := make([]string, 0)
+	for _, link := range legacy {
+		if _, ok := byProject[link.ProjectID]; !ok {
+			projectIDs = append(projectIDs, link.ProjectID)
+		}
+		byProject[link.ProjectID] = append(byProject[link.ProjectID], link)
+	}
+	sort.Strings(projectIDs)
+
+	migrated := 0
+	for _, projectID := range projectIDs {
+		links := byProject[projectID]
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].ID < links[j].ID
+		})
+
+		owners, err := svc.countOwners(projectID)
+		if err != nil {
+			return migrated, err
+		}
+
+		for index, link := range links {
+			role := entities.RoleMaintainer
+			if owners == 0 && index == 0 {
+				role = entities.RoleOwner
+			}
+			if err := svc.Repository.SetProjectMemberRole(link.UserID, projectID, role); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+	return migrated, nil
+}
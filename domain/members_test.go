@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+// membersFixture creates project "web" of organisation "acme" with direct owner "owner",
+// organisation owner "admin" and direct links of given legacy users without role
+func membersFixture(t *testing.T, legacy ...string) (SplitService, string) {
+	repo, err := storage.NewInMemStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewSplitService(repo)
+
+	for _, id := range append([]string{"owner", "admin", "guest"}, legacy...) {
+		if err := repo.CreateUser(entities.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projectID := "project-1"
+	must(t, repo.CreateOrganisation(entities.Organisation{ID: "org-1", Name: "acme"}))
+	must(t, repo.AttachUserToOrganisation("admin", "org-1", entities.RoleOwner))
+	must(t, repo.CreateProject(entities.Project{ID: projectID, Name: "web", OrganisationID: "org-1"}))
+	if len(legacy) == 0 {
+		must(t, repo.AttachProjectToUser("owner", projectID, entities.RoleOwner))
+	}
+	for _, id := range legacy {
+		must(t, repo.AttachProjectToUser(id, projectID, ""))
+	}
+	return svc, projectID
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemberManagement(t *testing.T) {
+	owner := entities.User{ID: "owner", Email: "owner@example.com"}
+	admin := entities.User{ID: "admin", Email: "admin@example.com"}
+
+	tests := []struct {
+		name   string
+		action func(svc SplitService) error
+		err    error
+	}{
+		{
+			name: "organisation member role is not changed in project",
+			action: func(svc SplitService) error {
+				return svc.ChangeMemberRole(owner, "web", "acme", "admin@example.com", entities.RoleViewer)
+			},
+			err: ErrOrganisationMember,
+		},
+		{
+			name: "organisation member is not removed from project",
+			action: func(svc SplitService) error {
+				return svc.RemoveMember(owner, "web", "acme", "admin@example.com")
+			},
+			err: ErrOrganisationMember,
+		},
+		{
+			name: "organisation member could not leave project",
+			action: func(svc SplitService) error {
+				return svc.LeaveProject(admin, "web", "acme")
+			},
+			err: ErrOrganisationMember,
+		},
+		{
+			name: "last direct owner could not leave while organisation owner exists",
+			action: func(svc SplitService) error {
+				return svc.LeaveProject(owner, "web", "acme")
+			},
+			err: ErrLastOwner,
+		},
+		{
+			name: "last direct owner could not be demoted",
+			action: func(svc SplitService) error {
+				return svc.ChangeMemberRole(admin, "web", "acme", "owner@example.com", entities.RoleMaintainer)
+			},
+			err: ErrLastOwner,
+		},
+		{
+			name: "user without access is not found",
+			action: func(svc SplitService) error {
+				return svc.RemoveMember(owner, "web", "acme", "guest@example.com")
+			},
+			err: storage.ErrMemberNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc, _ := membersFixture(t)
+			if err := test.action(svc); !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestLegacyMembersAreMaintainers(t *testing.T) {
+	svc, projectID := membersFixture(t, "legacy-1")
+
+	role, err := svc.projectRole("legacy-1", projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != entities.RoleMaintainer {
+		t.Fatalf("expected legacy member to be %s, got %s", entities.RoleMaintainer, role)
+	}
+}
+
+func TestMigrateMemberRoles(t *testing.T) {
+	svc, projectID := membersFixture(t, "legacy-1", "legacy-2", "legacy-3")
+
+	migrated, err := svc.MigrateMemberRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 3 {
+		t.Fatalf("expected 3 migrated members, got %d", migrated)
+	}
+
+	members, err := svc.Repository.GetProjectMembers(projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := map[string]int{}
+	for _, member := range members {
+		roles[member.Role]++
+	}
+	if roles[entities.RoleOwner] != 1 || roles[entities.RoleMaintainer] != 2 {
+		t.Fatalf("expected one owner and two maintainers, got %v", roles)
+	}
+
+	if migrated, err := svc.MigrateMemberRoles(); err != nil || migrated != 0 {
+		t.Fatalf("expected nothing to migrate second time, got %d, %v", migrated, err)
+	}
+}
@@ -0,0 +1,372 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"google.golang.org/appengine/datastore"
+)
+
+var ErrOrganisationExists = errors.New("organisation with such name already exists")
+var ErrLastOrganisationOwner = errors.New("organisation should have at least one owner")
+var ErrAmbiguousProject = errors.New("several projects have such name, specify organisation")
+
+func (svc *SplitService) CreateOrganisation(user entities.User, name string) error {
+	if name == "" {
+		return fmt.Errorf("organisation name cannot be empty")
+	}
+
+	if _, err := svc.Repository.GetOrganisationByName(name); err == nil {
+		return ErrOrganisationExists
+	}
+
+	id, _ := gonanoid.New()
+
+	if err := svc.Repository.CreateOrganisation(entities.Organisation{
+		ID:   id,
+		Name: name,
+	}); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.AttachUserToOrganisation(user.ID, id, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditOrganisationCreate, "", name)
+	return nil
+}
+
+func (svc *SplitService) organisationRole(userID string, organisationID string) (string, error) {
+	members, err := svc.Repository.GetOrganisationMembers(organisationID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, member := range members {
+		if member.UserID == userID {
+			return member.Role, nil
+		}
+	}
+	return "", storage.ErrOrganisationNotFound
+}
+
+// getOrganisation finds organisation by name and checks that user has at least required role in it
+func (svc *SplitService) getOrganisation(userID string, name string, required string) (*entities.Organisation, error) {
+	organisation, err := svc.Repository.GetOrganisationByName(name)
+	if err != nil {
+		return nil, storage.ErrOrganisationNotFound
+	}
+
+	role, err := svc.organisationRole(userID, organisation.ID)
+	if err != nil {
+		return nil, storage.ErrOrganisationNotFound
+	}
+
+	if roleRanks[role] < roleRanks[required] {
+		return nil, ErrPermissionDenied
+	}
+
+	return organisation, nil
+}
+
+func (svc *SplitService) AddOrganisationMember(user entities.User, organisationName string, email string, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role %s", role)
+	}
+
+	organisation, err := svc.getOrganisation(user.ID, organisationName, entities.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	member, err := svc.Repository.GetUserByEmail(email)
+	if err != nil {
+		return storage.ErrUserNotFound
+	}
+
+	if currentRole, err := svc.organisationRole(member.ID, organisation.ID); err == nil && currentRole == entities.RoleOwner && role != entities.RoleOwner {
+		if err := svc.ensureOrganisationOwnerLeft(organisation.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := svc.Repository.AttachUserToOrganisation(member.ID, organisation.ID, role); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditOrganisationMemberAdd, "", email+" as "+role+" in "+organisationName)
+	return nil
+}
+
+func (svc *SplitService) RemoveOrganisationMember(user entities.User, organisationName string, email string) error {
+	organisation, err := svc.getOrganisation(user.ID, organisationName, entities.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	member, err := svc.Repository.GetUserByEmail(email)
+	if err != nil {
+		return storage.ErrMemberNotFound
+	}
+
+	role, err := svc.organisationRole(member.ID, organisation.ID)
+	if err != nil {
+		return storage.ErrMemberNotFound
+	}
+
+	if role == entities.RoleOwner {
+		if err := svc.ensureOrganisationOwnerLeft(organisation.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := svc.Repository.UnlinkUserFromOrganisation(member.ID, organisation.ID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditOrganisationMemberDrop, "", email+" from "+organisationName)
+	return nil
+}
+
+// ensureOrganisationOwnerLeft checks that organisation will still have an owner after one owner is removed
+func (svc *SplitService) ensureOrganisationOwnerLeft(organisationID string) error {
+	members, err := svc.Repository.GetOrganisationMembers(organisationID)
+	if err != nil {
+		return err
+	}
+
+	owners := 0
+	for _, member := range members {
+		if member.Role == entities.RoleOwner {
+			owners++
+		}
+	}
+
+	if owners <= 1 {
+		return ErrLastOrganisationOwner
+	}
+	return nil
+}
+
+// TransferProject moves project to organisation, so it is available for organisation members
+// regardless of the users it was shared with, current organisation narrows lookup of project
+func (svc *SplitService) TransferProject(user entities.User, projectName string, currentOrganisation string, organisationName string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, currentOrganisation, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	organisation, err := svc.getOrganisation(user.ID, organisationName, entities.RoleMaintainer)
+	if err != nil {
+		return err
+	}
+
+	if _, err := svc.getOrganisationProjectID(organisation.ID, projectName); err == nil {
+		return fmt.Errorf("organisation %s already has project with such name", organisationName)
+	}
+
+	if err := svc.Repository.SetProjectOrganisation(projectID, organisation.ID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditProjectTransfer, projectID, organisationName)
+	return nil
+}
+
+func (svc *SplitService) getOrganisationProjectID(organisationID string, projectName string) (string, error) {
+	projects, err := svc.Repository.GetOrganisationProjects(organisationID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, project := range projects {
+		if project.Name == projectName {
+			return project.ID, nil
+		}
+	}
+	return "", storage.ErrProjectNotFound
+}
+
+// GetProjectIDByName looks for project shared with user directly and for projects of organisations user is member of,
+// organisation narrows lookup to its projects, soft deleted project is not available
+func (svc *SplitService) GetProjectIDByName(userID string, organisationName string, projectName string) (string, error) {
+	projectIDs, err := svc.projectIDsByName(userID, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	var active []string
+	deleted := false
+
+	for _, projectID := range projectIDs {
+		project, err := svc.Repository.GetProjectByID(projectID)
+		if err != nil {
+			return "", err
+		}
+		if project.DeletedAt != 0 {
+			deleted = true
+			continue
+		}
+		active = append(active, projectID)
+	}
+
+	switch {
+	case len(active) == 1:
+		return active[0], nil
+	case len(active) > 1:
+		return "", ErrAmbiguousProject
+	case deleted:
+		return "", ErrProjectDeleted
+	default:
+		return "", storage.ErrProjectNotFound
+	}
+}
+
+// FindProjectIDByName resolves project the same way, including soft deleted ones,
+// name matching several projects is ambiguous until organisation is specified
+func (svc *SplitService) FindProjectIDByName(userID string, organisationName string, projectName string) (string, error) {
+	projectIDs, err := svc.projectIDsByName(userID, organisationName, projectName)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(projectIDs) {
+	case 0:
+		return "", storage.ErrProjectNotFound
+	case 1:
+		return projectIDs[0], nil
+	default:
+		return "", ErrAmbiguousProject
+	}
+}
+
+func (svc *SplitService) projectIDsByName(userID string, organisationName string, projectName string) ([]string, error) {
+	if serviceProjectID, ok := svc.servicePrincipalProject(userID); ok {
+		projectID, err := svc.serviceProjectIDByName(serviceProjectID, projectName)
+		if err != nil {
+			return nil, nil
+		}
+		return []string{projectID}, nil
+	}
+
+	var organisationID string
+	if organisationName != "" {
+		organisation, err := svc.Repository.GetOrganisationByName(organisationName)
+		if err != nil {
+			return nil, nil
+		}
+		organisationID = organisation.ID
+	}
+
+	var matched []string
+	seen := make(map[string]bool)
+
+	userProjectIDs, err := svc.Repository.GetUserProjectIDsByName(userID, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, projectID := range userProjectIDs {
+		if organisationID != "" {
+			project, err := svc.Repository.GetProjectByID(projectID)
+			if err != nil {
+				return nil, err
+			}
+			if project.OrganisationID != organisationID {
+				continue
+			}
+		}
+		seen[projectID] = true
+		matched = append(matched, projectID)
+	}
+
+	memberships, err := svc.Repository.GetUserOrganisations(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, membership := range memberships {
+		if organisationID != "" && membership.OrganisationID != organisationID {
+			continue
+		}
+		projectID, err := svc.getOrganisationProjectID(membership.OrganisationID, projectName)
+		if err != nil || seen[projectID] {
+			continue
+		}
+		seen[projectID] = true
+		matched = append(matched, projectID)
+	}
+
+	return matched, nil
+}
+
+// hasProjectNamed checks whether user already has access to project with such name
+func (svc *SplitService) hasProjectNamed(userID string, projectName string) bool {
+	_, err := svc.FindProjectIDByName(userID, "", projectName)
+	return err == nil || errors.Is(err, ErrAmbiguousProject)
+}
+
+func (svc *SplitService) GetUserOrganisations(user entities.User) ([]entities.OrganisationWithMembers, error) {
+	memberships, err := svc.Repository.GetUserOrganisations(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	organisations := make([]entities.OrganisationWithMembers, 0, len(memberships))
+
+	for _, membership := range memberships {
+		organisation, err := svc.Repository.GetOrganisationByID(membership.OrganisationID)
+		if err != nil {
+			return nil, err
+		}
+
+		members, err := svc.Repository.GetOrganisationMembers(organisation.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		roles := make(map[string]string, len(members))
+		for _, member := range members {
+			roles[member.UserID] = member.Role
+		}
+
+		projectMembers, err := svc.toProjectMembers(roles)
+		if err != nil {
+			return nil, err
+		}
+
+		projects, err := svc.Repository.GetOrganisationProjects(organisation.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		projectNames := make([]string, 0, len(projects))
+		for _, project := range projects {
+			if project.DeletedAt != 0 {
+				continue
+			}
+			projectNames = append(projectNames, project.Name)
+		}
+
+		organisations = append(organisations, entities.OrganisationWithMembers{
+			Organisation: *organisation,
+			Role:         membership.Role,
+			Members:      projectMembers,
+			Projects:     projectNames,
+		})
+	}
+
+	return organisations, nil
+}
+
+func isNotFound(err error) bool {
+	return err.Error() == storage.ErrProjectNotFound.Error() || err.Error() == datastore.ErrNoSuchEntity.Error()
+}
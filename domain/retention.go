@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+const secondsInDay = 24 * 60 * 60
+
+var retentionActor = entities.User{Email: "retention policy"}
+
+func (svc *SplitService) SetRetentionPolicy(user entities.User, projectName string, organisationName string, policy entities.RetentionPolicy) error {
+	if policy.KeepLast < 0 || policy.MaxAgeDays < 0 {
+		return fmt.Errorf("retention policy values cannot be negative")
+	}
+
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.SetProjectRetention(projectID, policy); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditProjectRetention, projectID, fmt.Sprintf("keep last %d, max age %d days", policy.KeepLast, policy.MaxAgeDays))
+	return nil
+}
+
+// PreviewRetention lists sessions that would be purged by the given policy (dry run),
+// current project policy is used when policy is not specified
+func (svc *SplitService) PreviewRetention(user entities.User, projectName string, organisationName string, policy *entities.RetentionPolicy) (entities.RetentionPolicy, []entities.Session, error) {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return entities.RetentionPolicy{}, nil, err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleViewer); err != nil {
+		return entities.RetentionPolicy{}, nil, err
+	}
+
+	if policy == nil {
+		project, err := svc.Repository.GetProjectByID(projectID)
+		if err != nil {
+			return entities.RetentionPolicy{}, nil, err
+		}
+		current := project.Retention()
+		policy = &current
+	}
+
+	sessions, err := svc.Repository.GetProjectSessionList(projectID)
+	if err != nil {
+		return *policy, nil, err
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return *policy, nil, err
+	}
+
+	return *policy, ExpiredSessions(sessions, *policy, project.EstimationKeys, time.Now().Unix()), nil
+}
+
+// ExpiredSessions returns sessions not kept by retention policy, latest first.
+// Session is kept when it is one of policy.KeepLast latest sessions or finished within policy.MaxAgeDays,
+// running sessions and latest finished sessions used for estimation are always kept.
+// Estimation could be restricted by values of estimationKeys labels, so latest finished sessions are kept
+// for each combination of their values, which covers estimation by any subset of these keys
+func ExpiredSessions(sessions []entities.Session, policy entities.RetentionPolicy, estimationKeys []string, now int64) []entities.Session {
+	expired := make([]entities.Session, 0)
+
+	if !policy.Enabled() {
+		return expired
+	}
+
+	sorted := make([]entities.Session, len(sessions))
+	copy(sorted, sessions)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start > sorted[j].Start
+	})
+
+	// estimation uses latest finished sessions by end time
+	finished := make([]entities.Session, 0, len(sorted))
+	for _, session := range sorted {
+		if session.End != 0 {
+			finished = append(finished, session)
+		}
+	}
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[i].End > finished[j].End
+	})
+
+	estimation := make(map[string]bool, estimationSessions)
+	groups := make(map[string]int)
+	for _, session := range finished {
+		group := estimationGroup(session.Labels, estimationKeys)
+		if groups[group] < estimationSessions {
+			groups[group]++
+			estimation[session.ID] = true
+		}
+	}
+
+	minEnd := now - int64(policy.MaxAgeDays)*secondsInDay
+
+	for index, session := range sorted {
+		if session.End == 0 || estimation[session.ID] {
+			continue
+		}
+		if policy.KeepLast > 0 && index < policy.KeepLast {
+			continue
+		}
+		if policy.MaxAgeDays > 0 && session.End >= minEnd {
+			continue
+		}
+		expired = append(expired, session)
+	}
+
+	return expired
+}
+
+// ApplyRetention soft deletes sessions of all projects not kept by their retention policy,
+// so they could be restored until purged after deleted retention period
+func (svc *SplitService) ApplyRetention(now int64) (int, error) {
+	projects, err := svc.Repository.GetProjectsWithRetention()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, project := range projects {
+		if project.DeletedAt != 0 {
+			continue
+		}
+
+		sessions, err := svc.Repository.GetProjectSessionList(project.ID)
+		if err != nil {
+			return purged, err
+		}
+
+		expired := ExpiredSessions(sessions, project.Retention(), project.EstimationKeys, now)
+
+		for _, session := range expired {
+			if err := svc.Repository.SetSessionDeleted(session.ID, now); err != nil {
+				return purged, err
+			}
+		}
+
+		if len(expired) > 0 {
+			purged += len(expired)
+			svc.audit(retentionActor, entities.AuditRetentionPurge, project.ID, fmt.Sprintf("%d sessions", len(expired)))
+		}
+	}
+
+	return purged, nil
+}
+
+// RunRetention applies retention policies of projects every interval
+func (svc *SplitService) RunRetention(interval time.Duration) {
+	for {
+		purged, err := svc.ApplyRetention(time.Now().Unix())
+		if err != nil {
+			log.Printf("failed to apply retention policies: %s", err)
+		} else if purged > 0 {
+			log.Printf("purged %d sessions by retention policies", purged)
+		}
+		time.Sleep(interval)
+	}
+}
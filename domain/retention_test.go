@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+// finishedSessions returns sessions prefix1..prefixN finished at given days, one hour long
+func finishedSessions(prefix string, labels []entities.Label, days ...int64) []entities.Session {
+	sessions := make([]entities.Session, len(days))
+	for index, day := range days {
+		end := day * secondsInDay
+		sessions[index] = entities.Session{
+			ID:     fmt.Sprintf("%s%d", prefix, index+1),
+			Start:  end - 3600,
+			End:    end,
+			Labels: labels,
+		}
+	}
+	return sessions
+}
+
+func sessionIDs(sessions []entities.Session) []string {
+	ids := make([]string, len(sessions))
+	for index, session := range sessions {
+		ids[index] = session.ID
+	}
+	return ids
+}
+
+func TestExpiredSessions(t *testing.T) {
+	now := int64(100 * secondsInDay)
+	history := finishedSessions("s", nil, 10, 20, 30, 40, 50, 60, 70, 80)
+
+	running := entities.Session{ID: "running", Start: 5 * secondsInDay}
+
+	chrome := []entities.Label{{Key: "browser", Value: "chrome"}}
+	firefox := []entities.Label{{Key: "browser", Value: "firefox"}}
+	grouped := finishedSessions("n", nil, 1)
+	grouped = append(grouped, finishedSessions("f", firefox, 2, 3)...)
+	grouped = append(grouped, finishedSessions("c", chrome, 4, 5, 6, 7, 8, 9)...)
+
+	tests := []struct {
+		name           string
+		sessions       []entities.Session
+		policy         entities.RetentionPolicy
+		estimationKeys []string
+		expected       []string
+	}{
+		{
+			name:     "no sessions",
+			policy:   entities.RetentionPolicy{KeepLast: 1},
+			expected: []string{},
+		},
+		{
+			name:     "policy disabled",
+			sessions: history,
+			expected: []string{},
+		},
+		{
+			name:     "keep last sessions and sessions used for estimation",
+			sessions: history,
+			policy:   entities.RetentionPolicy{KeepLast: 2},
+			expected: []string{"s3", "s2", "s1"},
+		},
+		{
+			name:     "keep sessions finished within max age",
+			sessions: history,
+			policy:   entities.RetentionPolicy{MaxAgeDays: 75},
+			expected: []string{"s2", "s1"},
+		},
+		{
+			name:     "keep session matching any of keep last and max age",
+			sessions: history,
+			policy:   entities.RetentionPolicy{KeepLast: 7, MaxAgeDays: 75},
+			expected: []string{"s1"},
+		},
+		{
+			name:     "running session is kept",
+			sessions: append(finishedSessions("s", nil, 10, 20, 30, 40, 50, 60), running),
+			policy:   entities.RetentionPolicy{KeepLast: 1, MaxAgeDays: 1},
+			expected: []string{"s1"},
+		},
+		{
+			name:           "estimation sessions are kept for each label group",
+			sessions:       grouped,
+			policy:         entities.RetentionPolicy{KeepLast: 1},
+			estimationKeys: []string{"browser"},
+			expected:       []string{"c1"},
+		},
+		{
+			name:     "estimation sessions without label groups",
+			sessions: grouped,
+			policy:   entities.RetentionPolicy{KeepLast: 1},
+			expected: []string{"c1", "f2", "f1", "n1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expired := sessionIDs(ExpiredSessions(test.sessions, test.policy, test.estimationKeys, now))
+			if !reflect.DeepEqual(expired, test.expected) {
+				t.Fatalf("expected expired sessions %v, got %v", test.expected, expired)
+			}
+		})
+	}
+}
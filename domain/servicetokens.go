@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+func (svc *SplitService) AddServiceToken(user entities.User, projectName string, organisationName string, name string, expireAt int64, scopes []string) (entities.ServiceToken, error) {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return entities.ServiceToken{}, err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return entities.ServiceToken{}, err
+	}
+
+	if len(scopes) == 0 {
+		scopes = entities.DefaultScopes
+	}
+
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return entities.ServiceToken{}, fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+
+	id, _ := gonanoid.New()
+
+	token := entities.ServiceToken{
+		ID:        id,
+		ProjectID: projectID,
+		Name:      name,
+		CreatedBy: user.Email,
+		CreatedAt: time.Now().Unix(),
+		ExpireAt:  expireAt,
+		Scopes:    scopes,
+	}
+
+	if err := svc.Repository.CreateServiceToken(token); err != nil {
+		return entities.ServiceToken{}, err
+	}
+
+	svc.audit(user, entities.AuditServiceTokenCreate, projectID, name)
+	return token, nil
+}
+
+func (svc *SplitService) GetServiceTokens(user entities.User, projectName string, organisationName string) ([]entities.ServiceToken, error) {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleMaintainer); err != nil {
+		return nil, err
+	}
+
+	return svc.Repository.GetProjectServiceTokens(projectID)
+}
+
+func (svc *SplitService) DeleteServiceToken(user entities.User, projectName string, organisationName string, tokenID string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.DeleteServiceToken(projectID, tokenID); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditServiceTokenDelete, projectID, tokenID)
+	return nil
+}
+
+// servicePrincipalProject returns project of service token for principal user id
+func (svc *SplitService) servicePrincipalProject(userID string) (string, bool) {
+	if !strings.HasPrefix(userID, entities.ServicePrincipalPrefix) {
+		return "", false
+	}
+
+	token, err := svc.Repository.GetServiceToken(strings.TrimPrefix(userID, entities.ServicePrincipalPrefix))
+	if err != nil {
+		return "", true
+	}
+	return token.ProjectID, true
+}
+
+// serviceProjectIDByName resolves project of service principal, it has access only to project of its token
+func (svc *SplitService) serviceProjectIDByName(projectID string, projectName string) (string, error) {
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil || project.Name != projectName {
+		return "", storage.ErrProjectNotFound
+	}
+	return project.ID, nil
+}
@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+const (
+	minEstimationScale = 0.1
+	maxEstimationScale = 10
+)
+
+// SetEstimationSource declares other project which history is used to estimate specs without own history,
+// user should be able to read source project, empty source name removes it
+func (svc *SplitService) SetEstimationSource(user entities.User, projectName string, organisationName string, sourceName string, sourceOrganisation string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, projectID, entities.RoleMaintainer); err != nil {
+		return err
+	}
+
+	sourceID := ""
+
+	if sourceName != "" {
+		sourceID, err = svc.GetProjectIDByName(user.ID, sourceOrganisation, sourceName)
+		if err != nil {
+			return fmt.Errorf("estimation source: %w", err)
+		}
+
+		if err := svc.authorize(user.ID, sourceID, entities.RoleViewer); err != nil {
+			return fmt.Errorf("estimation source: %w", err)
+		}
+
+		if sourceID == projectID {
+			return fmt.Errorf("project cannot be estimation source of itself")
+		}
+	}
+
+	setBy := ""
+	if sourceID != "" {
+		setBy = user.ID
+	}
+
+	if err := svc.Repository.SetProjectEstimationSource(projectID, sourceID, setBy); err != nil {
+		return err
+	}
+
+	if sourceID != "" {
+		project, err := svc.Repository.GetProjectByID(projectID)
+		if err != nil {
+			return err
+		}
+		if len(project.EstimationKeys) > 0 {
+			if err := svc.Repository.AddProjectEstimationKeys(sourceID, project.EstimationKeys); err != nil {
+				return err
+			}
+		}
+	}
+
+	svc.audit(user, entities.AuditProjectSource, projectID, sourceName)
+	return nil
+}
+
+// GetEstimationSource returns source project of estimation with current scale,
+// nil when there is no source or user is not able to read it
+func (svc *SplitService) GetEstimationSource(userID string, projectID string) (*entities.EstimationSource, error) {
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	source, ok := svc.visibleEstimationSource(userID, project)
+	if !ok {
+		return nil, nil
+	}
+
+	own, err := svc.historyAverages(projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceAverages, err := svc.historyAverages(source.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, overlapping := estimationScale(own, sourceAverages)
+
+	return &entities.EstimationSource{
+		ProjectName: source.Name,
+		Scale:       scale,
+		Overlapping: overlapping,
+	}, nil
+}
+
+// estimationSourceProject returns source project unless it is not set, deleted
+// or user who set it lost access to it, so history is not shared after membership changes
+func (svc *SplitService) estimationSourceProject(project *entities.Project) (*entities.Project, bool) {
+	if project.EstimationSourceID == "" {
+		return nil, false
+	}
+
+	if err := svc.authorize(project.EstimationSourceSetBy, project.EstimationSourceID, entities.RoleViewer); err != nil {
+		return nil, false
+	}
+
+	source, err := svc.Repository.GetProjectByID(project.EstimationSourceID)
+	if err != nil {
+		return nil, false
+	}
+	return source, true
+}
+
+// visibleEstimationSource returns source project when user is able to read it as well
+func (svc *SplitService) visibleEstimationSource(userID string, project *entities.Project) (*entities.Project, bool) {
+	source, ok := svc.estimationSourceProject(project)
+	if !ok {
+		return nil, false
+	}
+
+	if err := svc.authorize(userID, source.ID, entities.RoleViewer); err != nil {
+		return nil, false
+	}
+	return source, true
+}
+
+// estimateFromSource estimates specs without own history by scaled history of estimation source project,
+// source sessions are restricted by the same labels
+func (svc *SplitService) estimateFromSource(projectID string, specs []entities.Spec, labels []entities.Label, averages map[string]float64, estimated map[string]bool) []entities.Spec {
+	if !hasMissingEstimates(specs, estimated) {
+		return specs
+	}
+
+	project, err := svc.Repository.GetProjectByID(projectID)
+	if err != nil {
+		return specs
+	}
+
+	source, ok := svc.estimationSourceProject(project)
+	if !ok {
+		return specs
+	}
+
+	sourceAverages, err := svc.historyAverages(source.ID, labels)
+	if err != nil || len(sourceAverages) == 0 {
+		return specs
+	}
+
+	scale, _ := estimationScale(averages, sourceAverages)
+
+	for index, spec := range specs {
+		if estimated[spec.FilePath] {
+			continue
+		}
+		average, ok := sourceAverages[spec.FilePath]
+		if !ok {
+			continue
+		}
+		// zero estimate marks new spec, so estimate is at least 1 second
+		specs[index].EstimatedDuration = int64(math.Max(1, math.Round(average*scale)))
+		estimated[spec.FilePath] = true
+	}
+
+	return specs
+}
+
+// estimationScale learns how much slower or faster project is comparing to its source
+// as ratio of total durations of specs present in history of both projects,
+// scale is 1 without overlapping specs and is limited to avoid outliers
+func estimationScale(own map[string]float64, source map[string]float64) (float64, int) {
+	var ownTotal, sourceTotal float64
+	overlapping := 0
+
+	for filePath, duration := range own {
+		sourceDuration, ok := source[filePath]
+		if !ok {
+			continue
+		}
+		ownTotal += duration
+		sourceTotal += sourceDuration
+		overlapping++
+	}
+
+	if overlapping == 0 || ownTotal == 0 || sourceTotal == 0 {
+		return 1, overlapping
+	}
+
+	return math.Min(maxEstimationScale, math.Max(minEstimationScale, ownTotal/sourceTotal)), overlapping
+}
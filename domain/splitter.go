@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/Shelex/split-specs/entities"
 	"github.com/Shelex/split-specs/storage"
@@ -12,6 +13,31 @@ import (
 )
 
 var ErrSessionFinished = errors.New("session finished")
+var ErrSessionAborted = errors.New("session aborted")
+
+// SessionAbortedError is returned for next spec of aborted session, so runners could stop instead of retrying
+type SessionAbortedError struct {
+	AbortedBy string
+}
+
+func (e *SessionAbortedError) Error() string {
+	return fmt.Sprintf("%s by %s", ErrSessionAborted, e.AbortedBy)
+}
+
+func (e *SessionAbortedError) Is(target error) bool {
+	return target == ErrSessionAborted
+}
+
+// Extensions are added to graphql error, so clients could distinguish abort from other failures
+func (e *SessionAbortedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":      "ABORTED",
+		"abortedBy": e.AbortedBy,
+	}
+}
+
+// estimationSessions is amount of latest finished sessions used to estimate spec duration
+const estimationSessions = 5
 
 type SplitService struct {
 	Repository storage.Storage
@@ -23,81 +49,228 @@ func NewSplitService(repo storage.Storage) SplitService {
 	}
 }
 
-func (svc *SplitService) AddSession(userID string, projectName string, sessionID string, inputSpecs []entities.Spec) error {
-	if sessionID == "" {
+// AddSession creates session with estimated specs, estimateBy restricts history used for estimation
+// to sessions having the same values of these label keys as the new session
+func (svc *SplitService) AddSession(user entities.User, projectName string, organisationName string, session entities.Session, inputSpecs []entities.Spec, estimateBy []string) error {
+	userID := user.ID
+
+	if session.ID == "" {
 		return fmt.Errorf("session id cannot be empty")
 	}
 
-	projectID, err := svc.Repository.GetUserProjectIDByName(userID, projectName)
+	if session.AbortAfterFailures < 0 {
+		return fmt.Errorf("abort after failures cannot be negative")
+	}
+
+	if err := ValidateLabels(session.Labels); err != nil {
+		return err
+	}
+
+	if session.CI != nil {
+		session.Labels = append(session.Labels, session.CI.Labels()...)
+		session.CI = nil
+	}
+
+	estimationLabels, err := selectLabels(session.Labels, estimateBy)
+	if err != nil {
+		return err
+	}
+
+	var organisationID string
+	var projectID string
+
+	if organisationName != "" {
+		organisation, orgErr := svc.getOrganisation(userID, organisationName, entities.RoleMaintainer)
+		if orgErr != nil {
+			return orgErr
+		}
+		organisationID = organisation.ID
+		projectID, err = svc.getOrganisationProjectID(organisationID, projectName)
+	} else {
+		projectID, err = svc.GetProjectIDByName(userID, "", projectName)
+	}
 
 	if err != nil {
-		if err.Error() == storage.ErrProjectNotFound.Error() || err.Error() == datastore.ErrNoSuchEntity.Error() {
-			newID, err := svc.AddProject(userID, projectName, sessionID)
+		if _, isService := svc.servicePrincipalProject(userID); isService {
+			return err
+		}
+		if isNotFound(err) {
+			newID, err := svc.AddProject(userID, projectName, organisationID)
 			if err != nil {
 				return err
 			}
 			projectID = newID
+			svc.audit(user, entities.AuditProjectCreate, projectID, projectName)
 		} else {
 			return err
 		}
+	} else if err := svc.authorize(userID, projectID, entities.RoleMaintainer); err != nil {
+		return err
+	}
+
+	session.ProjectID = projectID
+
+	if err := svc.recordEstimationKeys(projectID, estimateBy); err != nil {
+		return err
+	}
+
+	specs := svc.EstimateDuration(projectID, inputSpecs, estimationLabels)
+
+	if _, err := svc.Repository.CreateSession(session, specs); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditSessionCreate, projectID, session.ID)
+	return nil
+}
+
+func (svc *SplitService) CancelSession(user entities.User, sessionID string) error {
+	session, err := svc.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleMaintainer); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.AbortSession(sessionID, user.Email); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditSessionCancel, session.ProjectID, sessionID)
+	return nil
+}
+
+// DeleteSession marks session as deleted, it is purged after retention period and could be restored till then
+func (svc *SplitService) DeleteSession(user entities.User, sessionID string) error {
+	session, err := svc.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleMaintainer); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.SetSessionDeleted(sessionID, time.Now().Unix()); err != nil {
+		return err
 	}
 
-	specs := svc.EstimateDuration(projectID, inputSpecs)
+	svc.audit(user, entities.AuditSessionDelete, session.ProjectID, sessionID)
+	return nil
+}
 
-	if _, err := svc.Repository.CreateSession(projectID, sessionID, specs); err != nil {
+// DeleteProject marks project as deleted, it is purged after retention period and could be restored till then
+func (svc *SplitService) DeleteProject(user entities.User, projectName string, organisationName string) error {
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
+	if err != nil {
 		return err
 	}
 
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
+	if err := svc.Repository.SetProjectDeleted(projectID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditProjectDelete, projectID, projectName)
 	return nil
 }
 
-func (svc *SplitService) AddProject(userID string, projectName string, sessionID string) (string, error) {
+func (svc *SplitService) AddProject(userID string, projectName string, organisationID string) (string, error) {
 	id, _ := gonanoid.New()
 
 	if err := svc.Repository.CreateProject(entities.Project{
-		ID:   id,
-		Name: projectName,
+		ID:             id,
+		Name:           projectName,
+		OrganisationID: organisationID,
 	}); err != nil {
 		return "", err
 	}
 
-	if err := svc.Repository.AttachProjectToUser(userID, id); err != nil {
+	// organisation members have access to organisation projects by their organisation role
+	if organisationID != "" {
+		return id, nil
+	}
+
+	if err := svc.Repository.AttachProjectToUser(userID, id, entities.RoleOwner); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
 
-func (svc *SplitService) InviteUserToProject(user entities.User, guest string, projectName string) error {
-	projectID, err := svc.Repository.GetUserProjectIDByName(user.ID, projectName)
+func (svc *SplitService) InviteUserToProject(user entities.User, guest string, projectName string, organisationName string, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role %s", role)
+	}
+
+	projectID, err := svc.GetProjectIDByName(user.ID, organisationName, projectName)
 	if err != nil {
 		return fmt.Errorf("failed to share project")
 	}
 
+	if err := svc.authorize(user.ID, projectID, entities.RoleOwner); err != nil {
+		return err
+	}
+
 	guestUser, err := svc.Repository.GetUserByEmail(guest)
 	if err != nil {
 		return fmt.Errorf("failed to share project")
 	}
 
-	if _, err := svc.Repository.GetUserProjectIDByName(guestUser.ID, projectName); err != nil {
+	if svc.hasProjectNamed(guestUser.ID, projectName) {
 		return fmt.Errorf("user already has project with such name")
 	}
 
-	return svc.Repository.AttachProjectToUser(guestUser.ID, projectID)
+	if err := svc.Repository.AttachProjectToUser(guestUser.ID, projectID, role); err != nil {
+		return err
+	}
+
+	svc.audit(user, entities.AuditProjectShare, projectID, guest+" as "+role)
+	return nil
 }
 
-func (svc *SplitService) EstimateDuration(projectID string, specs []entities.Spec) []entities.Spec {
-	latestSessions, err := svc.Repository.GetProjectLatestSessions(projectID, 5)
+// EstimateDuration sets estimated duration of specs by average duration in latest finished sessions of project,
+// specs without own history are estimated by history of estimation source project and then by imported baseline.
+// Only sessions having all given labels are used when labels are specified
+func (svc *SplitService) EstimateDuration(projectID string, specs []entities.Spec, labels []entities.Label) []entities.Spec {
+	averages, err := svc.historyAverages(projectID, labels)
 	if err != nil {
 		return specs
 	}
 
+	estimated := make(map[string]bool, len(specs))
+
+	for index, spec := range specs {
+		average, ok := averages[spec.FilePath]
+		if ok {
+			specs[index].EstimatedDuration = int64(math.Round(average))
+			estimated[spec.FilePath] = true
+		}
+	}
+
+	specs = svc.estimateFromSource(projectID, specs, labels, averages, estimated)
+
+	return svc.estimateFromBaseline(projectID, specs, estimated)
+}
+
+// historyAverages returns average duration of spec files in latest finished sessions of project having all labels
+func (svc *SplitService) historyAverages(projectID string, labels []entities.Label) (map[string]float64, error) {
+	latestSessions, err := svc.Repository.GetProjectLatestSessions(projectID, estimationSessions, labels)
+	if err != nil {
+		return nil, err
+	}
+
 	var historicalSpecs []entities.Spec
 
 	for _, session := range latestSessions {
 		sessionSpecs, err := svc.Repository.GetSpecs(session.ID)
 		if err != nil {
-			return specs
+			return nil, err
 		}
 		historicalSpecs = append(historicalSpecs, sessionSpecs...)
 	}
@@ -125,41 +298,119 @@ func (svc *SplitService) EstimateDuration(projectID string, specs []entities.Spe
 		averages[historicalSpec.FilePath] = average
 	}
 
+	return averages, nil
+}
+
+func hasMissingEstimates(specs []entities.Spec, estimated map[string]bool) bool {
+	for _, spec := range specs {
+		if !estimated[spec.FilePath] {
+			return true
+		}
+	}
+	return false
+}
+
+// estimateFromBaseline uses imported baseline for specs without estimate
+func (svc *SplitService) estimateFromBaseline(projectID string, specs []entities.Spec, estimated map[string]bool) []entities.Spec {
+	if !hasMissingEstimates(specs, estimated) {
+		return specs
+	}
+
+	baselines, err := svc.Repository.GetProjectBaseline(projectID)
+	if err != nil || len(baselines) == 0 {
+		return specs
+	}
+
+	durations := make(map[string]int64, len(baselines))
+	for _, baseline := range baselines {
+		durations[baseline.FilePath] = baseline.Duration
+	}
+
 	for index, spec := range specs {
-		average, ok := averages[spec.FilePath]
-		if ok {
-			specs[index].EstimatedDuration = int64(math.Round(average))
+		if estimated[spec.FilePath] {
+			continue
+		}
+		duration, ok := durations[spec.FilePath]
+		if !ok {
+			continue
+		}
+		// zero estimate marks new spec, so baseline is at least 1 second
+		if duration < 1 {
+			duration = 1
 		}
+		specs[index].EstimatedDuration = duration
+		estimated[spec.FilePath] = true
 	}
 
 	return specs
 }
 
 func (svc *SplitService) GetProjectList(user entities.User) ([]string, error) {
+	if serviceProjectID, ok := svc.servicePrincipalProject(user.ID); ok {
+		return svc.GetProjectNames([]string{serviceProjectID}), nil
+	}
+
 	projectIds, err := svc.Repository.GetUserProjectIDs(user.ID)
 	if err != nil {
 		return []string{}, err
 	}
 
-	projects := make([]string, len(projectIds))
+	projects := make([]string, 0, len(projectIds))
+	seen := make(map[string]bool, len(projectIds))
 
-	for index, id := range projectIds {
+	for _, id := range projectIds {
 		project, err := svc.Repository.GetProjectByID(id)
 		if err != nil {
 			return []string{}, err
 		}
-		projects[index] = project.Name
+		seen[project.ID] = true
+		if project.DeletedAt != 0 {
+			continue
+		}
+		projects = append(projects, project.Name)
+	}
+
+	memberships, err := svc.Repository.GetUserOrganisations(user.ID)
+	if err != nil {
+		return []string{}, err
+	}
+
+	for _, membership := range memberships {
+		organisationProjects, err := svc.Repository.GetOrganisationProjects(membership.OrganisationID)
+		if err != nil {
+			return []string{}, err
+		}
+		for _, project := range organisationProjects {
+			if seen[project.ID] || project.DeletedAt != 0 {
+				continue
+			}
+			seen[project.ID] = true
+			projects = append(projects, project.Name)
+		}
 	}
 	return projects, nil
 }
 
-func (svc *SplitService) Next(sessionID string, machineID string, isPreviousSpecPassed bool) (string, error) {
+func (svc *SplitService) Next(user entities.User, sessionID string, machineID string, isPreviousSpecPassed bool) (string, error) {
+	session, err := svc.getSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleMaintainer); err != nil {
+		return "", err
+	}
+
 	if err := svc.Repository.EndSpec(sessionID, machineID, isPreviousSpecPassed); err != nil {
 		if err.Error() == datastore.ErrNoSuchEntity.Error() {
 			return "", storage.ErrSessionNotFound
 		}
 	}
 
+	if session.AbortedBy != "" {
+		return "", &SessionAbortedError{AbortedBy: session.AbortedBy}
+	}
+
 	specs, err := svc.Repository.GetSpecs(sessionID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get specs: %s", err)
@@ -169,6 +420,14 @@ func (svc *SplitService) Next(sessionID string, machineID string, isPreviousSpec
 		return "", fmt.Errorf("backlog for session %s is empty", sessionID)
 	}
 
+	if session.AbortAfterFailures > 0 && countFailedSpecs(specs) >= session.AbortAfterFailures {
+		abortedBy := fmt.Sprintf("fail-fast after %d failed specs", session.AbortAfterFailures)
+		if err := svc.Repository.AbortSession(sessionID, abortedBy); err != nil {
+			return "", fmt.Errorf("failed to abort session: %s", err)
+		}
+		return "", &SessionAbortedError{AbortedBy: abortedBy}
+	}
+
 	spec := svc.CalculateNext(specs)
 
 	if spec.FilePath == "" {
@@ -208,10 +467,20 @@ func getLongestSpec(specs []entities.Spec) entities.Spec {
 	return longestSpec
 }
 
+func countFailedSpecs(specs []entities.Spec) int {
+	failed := 0
+	for _, spec := range specs {
+		if spec.End != 0 && !spec.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
 func getSpecsToRun(specs []entities.Spec) []entities.Spec {
 	filtered := make([]entities.Spec, 0)
 	for _, spec := range specs {
-		if spec.Start == 0 {
+		if spec.Start == 0 && !spec.Skipped {
 			filtered = append(filtered, spec)
 		}
 	}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"sort"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+// CalculateSessionStats summarizes progress of the session backlog at the given moment (unix seconds).
+func CalculateSessionStats(session entities.SessionWithSpecs, now int64) entities.SessionStats {
+	stats := entities.SessionStats{
+		Total: len(session.Specs),
+	}
+
+	busyTime := make(map[string]int64)
+	activeMachines := make(map[string]bool)
+	var remainingWork int64
+
+	for _, spec := range session.Specs {
+		if spec.Skipped {
+			stats.Skipped++
+			continue
+		}
+
+		if spec.Start == 0 {
+			remainingWork += spec.EstimatedDuration
+			continue
+		}
+
+		stats.Started++
+
+		if spec.End != 0 {
+			stats.Finished++
+			if !spec.Passed {
+				stats.Failed++
+			}
+			busyTime[spec.AssignedTo] += spec.End - spec.Start
+			continue
+		}
+
+		running := now - spec.Start
+		busyTime[spec.AssignedTo] += running
+		activeMachines[spec.AssignedTo] = true
+
+		if left := spec.EstimatedDuration - running; left > 0 {
+			remainingWork += left
+		}
+	}
+
+	if session.Start != 0 {
+		end := session.End
+		if end == 0 {
+			end = now
+		}
+		stats.Elapsed = end - session.Start
+	}
+
+	stats.ActiveMachines = len(activeMachines)
+
+	if session.End == 0 {
+		// machines that already finished their specs will most likely request next ones,
+		// so all known machines are taken into account when nobody is running at the moment
+		machines := stats.ActiveMachines
+		if machines == 0 {
+			machines = len(busyTime)
+		}
+		if machines == 0 {
+			machines = 1
+		}
+		stats.PredictedRemaining = (remainingWork + int64(machines) - 1) / int64(machines)
+	}
+
+	stats.Machines = make([]entities.MachineStats, 0, len(busyTime))
+	for machine, busy := range busyTime {
+		stats.Machines = append(stats.Machines, entities.MachineStats{
+			Machine:  machine,
+			BusyTime: busy,
+		})
+	}
+
+	sort.Slice(stats.Machines, func(i, j int) bool {
+		return stats.Machines[i].Machine < stats.Machines[j].Machine
+	})
+
+	return stats
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+func TestCalculateSessionStats(t *testing.T) {
+	const now = 100
+
+	tests := []struct {
+		name     string
+		session  entities.SessionWithSpecs
+		expected entities.SessionStats
+	}{
+		{
+			name:    "empty session",
+			session: entities.SessionWithSpecs{ID: "empty"},
+			expected: entities.SessionStats{
+				Machines: []entities.MachineStats{},
+			},
+		},
+		{
+			name: "running session",
+			session: entities.SessionWithSpecs{
+				ID:    "running",
+				Start: 10,
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 10, End: 40, Passed: true, AssignedTo: "m1"},
+					{FilePath: "b", Start: 40, EstimatedDuration: 100, AssignedTo: "m1"},
+					{FilePath: "c", Start: 20, End: 50, AssignedTo: "m2"},
+					{FilePath: "d", EstimatedDuration: 30},
+					{FilePath: "e", EstimatedDuration: 31},
+					{FilePath: "f", Skipped: true},
+				},
+			},
+			expected: entities.SessionStats{
+				Total:              6,
+				Started:            3,
+				Finished:           2,
+				Failed:             1,
+				Skipped:            1,
+				Elapsed:            90,
+				PredictedRemaining: 101,
+				ActiveMachines:     1,
+				Machines: []entities.MachineStats{
+					{Machine: "m1", BusyTime: 90},
+					{Machine: "m2", BusyTime: 30},
+				},
+			},
+		},
+		{
+			name: "running spec exceeding estimate",
+			session: entities.SessionWithSpecs{
+				ID:    "overdue",
+				Start: 50,
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 50, EstimatedDuration: 10, AssignedTo: "m1"},
+				},
+			},
+			expected: entities.SessionStats{
+				Total:          1,
+				Started:        1,
+				Elapsed:        50,
+				ActiveMachines: 1,
+				Machines: []entities.MachineStats{
+					{Machine: "m1", BusyTime: 50},
+				},
+			},
+		},
+		{
+			name: "remaining work split between idle machines",
+			session: entities.SessionWithSpecs{
+				ID:    "idle",
+				Start: 10,
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 10, End: 20, Passed: true, AssignedTo: "m1"},
+					{FilePath: "b", Start: 10, End: 30, Passed: true, AssignedTo: "m2"},
+					{FilePath: "c", EstimatedDuration: 51},
+				},
+			},
+			expected: entities.SessionStats{
+				Total:              3,
+				Started:            2,
+				Finished:           2,
+				Elapsed:            90,
+				PredictedRemaining: 26,
+				Machines: []entities.MachineStats{
+					{Machine: "m1", BusyTime: 10},
+					{Machine: "m2", BusyTime: 20},
+				},
+			},
+		},
+		{
+			name: "finished session",
+			session: entities.SessionWithSpecs{
+				ID:    "finished",
+				Start: 10,
+				End:   60,
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 10, End: 60, Passed: true, AssignedTo: "m1"},
+				},
+			},
+			expected: entities.SessionStats{
+				Total:    1,
+				Started:  1,
+				Finished: 1,
+				Elapsed:  50,
+				Machines: []entities.MachineStats{
+					{Machine: "m1", BusyTime: 50},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stats := CalculateSessionStats(test.session, now)
+			if !reflect.DeepEqual(stats, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/storage"
+)
+
+func (svc *SplitService) GetSessionTimeline(user entities.User, sessionID string) (entities.SessionTimeline, error) {
+	session, err := svc.Repository.GetSessionWithSpecs(sessionID)
+	if err != nil || session.DeletedAt != 0 {
+		return entities.SessionTimeline{}, storage.ErrSessionNotFound
+	}
+
+	if err := svc.authorize(user.ID, session.ProjectID, entities.RoleViewer); err != nil {
+		return entities.SessionTimeline{}, storage.ErrSessionNotFound
+	}
+
+	return BuildSessionTimeline(session, time.Now().Unix()), nil
+}
+
+// BuildSessionTimeline reconstructs which specs every machine was running and when it was idle.
+// Machine time is counted from session start till the end of the latest spec of that machine,
+// specs still running are counted till the given moment (unix seconds).
+func BuildSessionTimeline(session entities.SessionWithSpecs, now int64) entities.SessionTimeline {
+	timeline := entities.SessionTimeline{
+		SessionID: session.ID,
+		Machines:  make([]entities.MachineTimeline, 0),
+	}
+
+	byMachine := make(map[string][]entities.SpecInterval)
+
+	for _, spec := range session.Specs {
+		if spec.Start == 0 {
+			continue
+		}
+
+		end := spec.End
+		if end == 0 {
+			end = now
+		}
+
+		byMachine[spec.AssignedTo] = append(byMachine[spec.AssignedTo], entities.SpecInterval{
+			FilePath: spec.FilePath,
+			Start:    spec.Start,
+			End:      end,
+			Passed:   spec.Passed,
+		})
+	}
+
+	for machine, specs := range byMachine {
+		timeline.Machines = append(timeline.Machines, buildMachineTimeline(machine, session.Start, specs))
+	}
+
+	sort.Slice(timeline.Machines, func(i, j int) bool {
+		return timeline.Machines[i].Machine < timeline.Machines[j].Machine
+	})
+
+	if len(timeline.Machines) > 0 {
+		longest := timeline.Machines[0].TotalTime
+		shortest := timeline.Machines[0].TotalTime
+		for _, machine := range timeline.Machines {
+			if machine.TotalTime > longest {
+				longest = machine.TotalTime
+			}
+			if machine.TotalTime < shortest {
+				shortest = machine.TotalTime
+			}
+		}
+		timeline.Imbalance = longest - shortest
+	}
+
+	return timeline
+}
+
+func buildMachineTimeline(machine string, sessionStart int64, specs []entities.SpecInterval) entities.MachineTimeline {
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Start < specs[j].Start
+	})
+
+	timeline := entities.MachineTimeline{
+		Machine:  machine,
+		Specs:    specs,
+		IdleGaps: make([]entities.TimeInterval, 0),
+	}
+
+	origin := sessionStart
+	if origin == 0 || origin > specs[0].Start {
+		origin = specs[0].Start
+	}
+
+	previousEnd := origin
+
+	for _, spec := range specs {
+		if spec.Start > previousEnd {
+			timeline.IdleGaps = append(timeline.IdleGaps, entities.TimeInterval{
+				Start: previousEnd,
+				End:   spec.Start,
+			})
+			timeline.IdleTime += spec.Start - previousEnd
+		}
+
+		if spec.End > previousEnd {
+			previousEnd = spec.End
+		}
+	}
+
+	timeline.TotalTime = previousEnd - origin
+	timeline.BusyTime = timeline.TotalTime - timeline.IdleTime
+
+	return timeline
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+func TestBuildSessionTimeline(t *testing.T) {
+	const now = 100
+
+	tests := []struct {
+		name     string
+		session  entities.SessionWithSpecs
+		expected entities.SessionTimeline
+	}{
+		{
+			name:    "empty session",
+			session: entities.SessionWithSpecs{ID: "empty"},
+			expected: entities.SessionTimeline{
+				SessionID: "empty",
+				Machines:  []entities.MachineTimeline{},
+			},
+		},
+		{
+			name: "specs not started",
+			session: entities.SessionWithSpecs{
+				ID:    "pending",
+				Start: 10,
+				Specs: []entities.Spec{{FilePath: "a"}, {FilePath: "b"}},
+			},
+			expected: entities.SessionTimeline{
+				SessionID: "pending",
+				Machines:  []entities.MachineTimeline{},
+			},
+		},
+		{
+			name: "running session",
+			session: entities.SessionWithSpecs{
+				ID:    "running",
+				Start: 10,
+				Specs: []entities.Spec{
+					{FilePath: "b", Start: 40, AssignedTo: "m1"},
+					{FilePath: "c", Start: 20, End: 50, AssignedTo: "m2"},
+					{FilePath: "a", Start: 10, End: 30, Passed: true, AssignedTo: "m1"},
+					{FilePath: "d"},
+				},
+			},
+			expected: entities.SessionTimeline{
+				SessionID: "running",
+				Imbalance: 50,
+				Machines: []entities.MachineTimeline{
+					{
+						Machine: "m1",
+						Specs: []entities.SpecInterval{
+							{FilePath: "a", Start: 10, End: 30, Passed: true},
+							{FilePath: "b", Start: 40, End: 100},
+						},
+						IdleGaps:  []entities.TimeInterval{{Start: 30, End: 40}},
+						BusyTime:  80,
+						IdleTime:  10,
+						TotalTime: 90,
+					},
+					{
+						Machine:   "m2",
+						Specs:     []entities.SpecInterval{{FilePath: "c", Start: 20, End: 50}},
+						IdleGaps:  []entities.TimeInterval{{Start: 10, End: 20}},
+						BusyTime:  30,
+						IdleTime:  10,
+						TotalTime: 40,
+					},
+				},
+			},
+		},
+		{
+			name: "overlapping specs of machine",
+			session: entities.SessionWithSpecs{
+				ID:    "overlap",
+				Start: 10,
+				End:   70,
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 10, End: 50, Passed: true, AssignedTo: "m1"},
+					{FilePath: "b", Start: 20, End: 30, Passed: true, AssignedTo: "m1"},
+					{FilePath: "c", Start: 60, End: 70, Passed: true, AssignedTo: "m1"},
+				},
+			},
+			expected: entities.SessionTimeline{
+				SessionID: "overlap",
+				Machines: []entities.MachineTimeline{
+					{
+						Machine: "m1",
+						Specs: []entities.SpecInterval{
+							{FilePath: "a", Start: 10, End: 50, Passed: true},
+							{FilePath: "b", Start: 20, End: 30, Passed: true},
+							{FilePath: "c", Start: 60, End: 70, Passed: true},
+						},
+						IdleGaps:  []entities.TimeInterval{{Start: 50, End: 60}},
+						BusyTime:  50,
+						IdleTime:  10,
+						TotalTime: 60,
+					},
+				},
+			},
+		},
+		{
+			name: "session without start",
+			session: entities.SessionWithSpecs{
+				ID: "unstarted",
+				Specs: []entities.Spec{
+					{FilePath: "a", Start: 30, End: 40, Passed: true, AssignedTo: "m1"},
+				},
+			},
+			expected: entities.SessionTimeline{
+				SessionID: "unstarted",
+				Machines: []entities.MachineTimeline{
+					{
+						Machine:   "m1",
+						Specs:     []entities.SpecInterval{{FilePath: "a", Start: 30, End: 40, Passed: true}},
+						IdleGaps:  []entities.TimeInterval{},
+						BusyTime:  10,
+						TotalTime: 10,
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeline := BuildSessionTimeline(test.session, now)
+			if !reflect.DeepEqual(timeline, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, timeline)
+			}
+		})
+	}
+}
@@ -4,34 +4,388 @@ type User struct {
 	ID       string `datastore:"id"`
 	Email    string `datastore:"email"`
 	Password string `datastore:"password"`
+	// TokensRevokedAt invalidates user tokens issued before this timestamp
+	TokensRevokedAt int64 `datastore:"tokensRevokedAt"`
+	// OIDCIssuer and OIDCSubject identify user signed in with single sign-on
+	OIDCIssuer  string `datastore:"oidcIssuer"`
+	OIDCSubject string `datastore:"oidcSubject"`
+	// IP is address of current request used for audit log, it is not stored
+	IP string `datastore:"-"`
 }
 
+const (
+	AuditSessionCreate          = "session.create"
+	AuditSessionCancel          = "session.cancel"
+	AuditSessionDelete          = "session.delete"
+	AuditSessionRestore         = "session.restore"
+	AuditProjectCreate          = "project.create"
+	AuditProjectDelete          = "project.delete"
+	AuditProjectRestore         = "project.restore"
+	AuditProjectRetention       = "project.retention"
+	AuditProjectExport          = "project.export"
+	AuditProjectImport          = "project.import"
+	AuditProjectBaseline        = "project.baseline"
+	AuditProjectSource          = "project.estimation_source"
+	AuditRetentionPurge         = "session.retention_purge"
+	AuditProjectShare           = "project.share"
+	AuditProjectTransfer        = "project.transfer"
+	AuditMemberRoleChange       = "member.role_change"
+	AuditMemberRemove           = "member.remove"
+	AuditMemberLeave            = "member.leave"
+	AuditOrganisationCreate     = "organisation.create"
+	AuditOrganisationMemberAdd  = "organisation.member_add"
+	AuditOrganisationMemberDrop = "organisation.member_remove"
+	AuditServiceTokenCreate     = "service_token.create"
+	AuditServiceTokenDelete     = "service_token.delete"
+	AuditApiKeyCreate           = "api_key.create"
+	AuditApiKeyDelete           = "api_key.delete"
+	AuditPasswordChange         = "password.change"
+	AuditPasswordReset          = "password.reset"
+	AuditLogoutEverywhere       = "logout.everywhere"
+)
+
+// AuditEntry is append-only record of security-relevant or destructive action,
+// ProjectID is empty for account actions
+type AuditEntry struct {
+	ID         string `datastore:"id"`
+	ProjectID  string `datastore:"projectId"`
+	ActorID    string `datastore:"actorId"`
+	ActorEmail string `datastore:"actorEmail"`
+	Action     string `datastore:"action"`
+	Target     string `datastore:"target"`
+	Timestamp  int64  `datastore:"timestamp"`
+	IP         string `datastore:"ip"`
+}
+
+// RefreshToken is stored by sha256 hash of the token, raw token is known only to client
+type RefreshToken struct {
+	ID        string `datastore:"id"`
+	UserID    string `datastore:"userId"`
+	CreatedAt int64  `datastore:"createdAt"`
+	ExpireAt  int64  `datastore:"expireAt"`
+}
+
+// PasswordReset is stored by sha256 hash of the token sent to user
+type PasswordReset struct {
+	ID       string `datastore:"id"`
+	UserID   string `datastore:"userId"`
+	ExpireAt int64  `datastore:"expireAt"`
+}
+
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpireAt     int64
+}
+
+const (
+	RoleOwner      = "owner"
+	RoleMaintainer = "maintainer"
+	RoleViewer     = "viewer"
+)
+
 type UserProject struct {
 	ID        string `datastore:"id"`
 	UserID    string `datastore:"userId"`
 	ProjectID string `datastore:"projectId"`
+	Role      string `datastore:"role"`
+}
+
+type ProjectMember struct {
+	UserID string
+	Email  string
+	Role   string
 }
 
 type Session struct {
-	ID        string `datastore:"id"`
-	ProjectID string `datastore:"projectId"`
-	Start     int64  `datastore:"start"`
-	End       int64  `datastore:"end"`
+	ID                 string  `datastore:"id"`
+	ProjectID          string  `datastore:"projectId"`
+	Start              int64   `datastore:"start"`
+	End                int64   `datastore:"end"`
+	AbortAfterFailures int     `datastore:"abortAfterFailures"`
+	AbortedBy          string  `datastore:"abortedBy"`
+	DeletedAt          int64   `datastore:"deletedAt"`
+	Labels             []Label `datastore:"labels,noindex"`
+	// LabelIndex is indexed copy of labels used to filter sessions, it is set by storage
+	LabelIndex []string `datastore:"labelIndex"`
+	// CI is metadata of CI build creating session, it is stored as labels with reserved keys
+	CI *CIMetadata `datastore:"-"`
 }
 
 type SessionWithSpecs struct {
-	ID        string `datastore:"id"`
-	ProjectID string `datastore:"projectId"`
-	Specs     []Spec
-	Start     int64 `datastore:"start"`
-	End       int64 `datastore:"end"`
+	ID                 string `datastore:"id"`
+	ProjectID          string `datastore:"projectId"`
+	Specs              []Spec
+	Start              int64    `datastore:"start"`
+	End                int64    `datastore:"end"`
+	AbortAfterFailures int      `datastore:"abortAfterFailures"`
+	AbortedBy          string   `datastore:"abortedBy"`
+	DeletedAt          int64    `datastore:"deletedAt"`
+	Labels             []Label  `datastore:"labels,noindex"`
+	LabelIndex         []string `datastore:"labelIndex"`
+}
+
+// Label is session metadata describing where it was run: branch, commit, environment, browser
+type Label struct {
+	Key   string `datastore:"key"`
+	Value string `datastore:"value"`
+}
+
+// Index returns indexed form of label, it is unambiguous as label keys cannot contain "="
+func (l Label) Index() string {
+	return l.Key + "=" + l.Value
+}
+
+// LabelIndex returns indexed form of labels
+func LabelIndex(labels []Label) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	index := make([]string, len(labels))
+	for i, label := range labels {
+		index[i] = label.Index()
+	}
+	return index
+}
+
+// HasLabels checks that session labels include all required labels
+func HasLabels(labels []Label, required []Label) bool {
+	for _, label := range required {
+		found := false
+		for _, sessionLabel := range labels {
+			if sessionLabel == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// CI label keys are reserved for metadata of CI build which created session
+const (
+	LabelCIPrefix      = "ci."
+	LabelCIProvider    = "ci.provider"
+	LabelCIRunID       = "ci.runId"
+	LabelCICommit      = "ci.commit"
+	LabelCIBranch      = "ci.branch"
+	LabelCIPullRequest = "ci.pullRequest"
+	LabelCIJobURL      = "ci.jobUrl"
+)
+
+// CIMetadata links session to CI build, it is stored as session labels
+type CIMetadata struct {
+	Provider    string
+	RunID       string
+	Commit      string
+	Branch      string
+	PullRequest string
+	JobURL      string
+}
+
+func (m *CIMetadata) fields() map[string]*string {
+	return map[string]*string{
+		LabelCIProvider:    &m.Provider,
+		LabelCIRunID:       &m.RunID,
+		LabelCICommit:      &m.Commit,
+		LabelCIBranch:      &m.Branch,
+		LabelCIPullRequest: &m.PullRequest,
+		LabelCIJobURL:      &m.JobURL,
+	}
+}
+
+// Labels returns labels for non-empty fields of metadata
+func (m CIMetadata) Labels() []Label {
+	keys := []string{LabelCIProvider, LabelCIRunID, LabelCICommit, LabelCIBranch, LabelCIPullRequest, LabelCIJobURL}
+	fields := m.fields()
+
+	var labels []Label
+	for _, key := range keys {
+		if value := *fields[key]; value != "" {
+			labels = append(labels, Label{Key: key, Value: value})
+		}
+	}
+	return labels
+}
+
+// CIMetadataFromLabels collects ci metadata from session labels, nil when session was not created in CI
+func CIMetadataFromLabels(labels []Label) *CIMetadata {
+	var metadata CIMetadata
+	fields := metadata.fields()
+	found := false
+
+	for _, label := range labels {
+		if field, ok := fields[label.Key]; ok {
+			*field = label.Value
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return &metadata
+}
+
+type SessionStats struct {
+	Total              int
+	Started            int
+	Finished           int
+	Failed             int
+	Skipped            int
+	Elapsed            int64
+	PredictedRemaining int64
+	ActiveMachines     int
+	Machines           []MachineStats
+}
+
+type MachineStats struct {
+	Machine  string
+	BusyTime int64
+}
+
+type SessionTimeline struct {
+	SessionID string
+	Machines  []MachineTimeline
+	Imbalance int64
+}
+
+type MachineTimeline struct {
+	Machine   string
+	Specs     []SpecInterval
+	IdleGaps  []TimeInterval
+	BusyTime  int64
+	IdleTime  int64
+	TotalTime int64
+}
+
+type SpecInterval struct {
+	FilePath string
+	Start    int64
+	End      int64
+	Passed   bool
+}
+
+type TimeInterval struct {
+	Start int64
+	End   int64
+}
+
+type ProjectAnalytics struct {
+	Window        int
+	PassRate      float64
+	DurationTrend []SessionDuration
+	SlowestSpecs  []SpecDurationHistory
+	Regressions   []SpecRegression
+}
+
+type AnalyticsOptions struct {
+	Window              int
+	Slowest             int
+	RegressionThreshold float64
+}
+
+type SessionDuration struct {
+	SessionID string
+	Start     int64
+	End       int64
+	Duration  int64
+}
+
+type SpecDurationHistory struct {
+	FilePath        string
+	AverageDuration int64
+	History         []SpecRunDuration
+}
+
+type SpecRunDuration struct {
+	SessionID string
+	End       int64
+	Duration  int64
+	Passed    bool
+}
+
+type SpecRegression struct {
+	FilePath         string
+	BaselineDuration int64
+	LatestDuration   int64
+	Ratio            float64
 }
 
 type Project struct {
+	ID             string `datastore:"id"`
+	Name           string `datastore:"name"`
+	OrganisationID string `datastore:"organisationId"`
+	DeletedAt      int64  `datastore:"deletedAt"`
+	// retention policy of project sessions, zero values mean no limit
+	RetentionKeepLast   int `datastore:"retentionKeepLast"`
+	RetentionMaxAgeDays int `datastore:"retentionMaxAgeDays"`
+	// EstimationSourceID is project which history is used for specs without own history
+	EstimationSourceID string `datastore:"estimationSourceId"`
+	// EstimationSourceSetBy is user who set estimation source, source is used while this user is able to read it
+	EstimationSourceSetBy string `datastore:"estimationSourceSetBy"`
+	// EstimationKeys are label keys which history of project is estimated by,
+	// retention keeps estimation history for each combination of their values
+	EstimationKeys []string `datastore:"estimationKeys"`
+}
+
+// RetentionPolicy keeps last KeepLast sessions or sessions finished within MaxAgeDays,
+// older sessions are purged, policy with zero values is disabled
+type RetentionPolicy struct {
+	KeepLast   int
+	MaxAgeDays int
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAgeDays > 0
+}
+
+func (p Project) Retention() RetentionPolicy {
+	return RetentionPolicy{
+		KeepLast:   p.RetentionKeepLast,
+		MaxAgeDays: p.RetentionMaxAgeDays,
+	}
+}
+
+// EstimationSource is project sharing its history for estimation,
+// Scale is ratio of durations of the same specs in project and its source
+type EstimationSource struct {
+	ProjectName string
+	Scale       float64
+	Overlapping int
+}
+
+// SpecBaseline is synthetic duration of spec file (seconds) imported from timing file,
+// it is used for estimation until spec has real history
+type SpecBaseline struct {
+	ProjectID string `datastore:"projectId"`
+	FilePath  string `datastore:"filePath"`
+	Duration  int64  `datastore:"duration"`
+}
+
+type Organisation struct {
 	ID   string `datastore:"id"`
 	Name string `datastore:"name"`
 }
 
+type OrganisationMember struct {
+	ID             string `datastore:"id"`
+	OrganisationID string `datastore:"organisationId"`
+	UserID         string `datastore:"userId"`
+	Role           string `datastore:"role"`
+}
+
+type OrganisationWithMembers struct {
+	Organisation
+	Role     string
+	Members  []ProjectMember
+	Projects []string
+}
+
 type ProjectFull struct {
 	Sessions      []SessionWithSpecs
 	TotalSessions int
@@ -41,20 +395,65 @@ type ProjectFull struct {
 type Spec struct {
 	ID                string `datastore:"id"`
 	SessionID         string `datastore:"sessionId"`
+	ProjectID         string `datastore:"projectId"`
 	FilePath          string `datastore:"filePath"`
 	Tests             []string
 	EstimatedDuration int64  `datastore:"estimatedDuration"`
 	Start             int64  `datastore:"start"`
 	End               int64  `datastore:"end"`
 	Passed            bool   `datastore:"passed"`
+	Skipped           bool   `datastore:"skipped"`
 	AssignedTo        string `datastore:"assignedTo"`
 }
 
+const (
+	ScopeProjectRead   = "project:read"
+	ScopeProjectManage = "project:manage"
+	ScopeSessionCreate = "session:create"
+	ScopeSessionManage = "session:manage"
+	ScopeSpecNext      = "spec:next"
+)
+
+// AllScopes could be granted to api key, account management is available only for user tokens
+var AllScopes = []string{
+	ScopeProjectRead,
+	ScopeProjectManage,
+	ScopeSessionCreate,
+	ScopeSessionManage,
+	ScopeSpecNext,
+}
+
+// DefaultScopes are granted to api key when scopes are not specified, enough to run tests
+var DefaultScopes = []string{
+	ScopeProjectRead,
+	ScopeSessionCreate,
+	ScopeSpecNext,
+}
+
 type ApiKey struct {
-	ID       string `datastore:"id"`
-	UserID   string `datastore:"userId"`
-	Name     string `datastore:"name"`
-	ExpireAt int64  `datastore:"expireAt"`
+	ID           string   `datastore:"id"`
+	UserID       string   `datastore:"userId"`
+	Name         string   `datastore:"name"`
+	ExpireAt     int64    `datastore:"expireAt"`
+	Scopes       []string `datastore:"scopes"`
+	Projects     []string `datastore:"projects"`
+	LastUsedAt   int64    `datastore:"lastUsedAt"`
+	LastIP       string   `datastore:"lastIp"`
+	RequestCount int64    `datastore:"requestCount"`
+}
+
+// ServicePrincipalPrefix marks user id of principal authenticated with project service token
+const ServicePrincipalPrefix = "service:"
+
+type ServiceToken struct {
+	ID         string   `datastore:"id"`
+	ProjectID  string   `datastore:"projectId"`
+	Name       string   `datastore:"name"`
+	CreatedBy  string   `datastore:"createdBy"`
+	CreatedAt  int64    `datastore:"createdAt"`
+	ExpireAt   int64    `datastore:"expireAt"`
+	LastUsedAt int64    `datastore:"lastUsedAt"`
+	Scopes     []string `datastore:"scopes"`
 }
 
 type Pagination struct {
@@ -2,13 +2,18 @@ package auth
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Shelex/split-specs/internal/users"
 	"github.com/Shelex/split-specs/pkg/jwt"
 )
 
 var userCtxKey = &contextKey{"user"}
+var ipCtxKey = &contextKey{"ip"}
 
 type contextKey struct {
 	name string
@@ -17,6 +22,8 @@ type contextKey struct {
 func Middleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), ipCtxKey, ClientIP(r)))
+
 			token := r.Header.Get("Authorization")
 
 			// Allow unauthenticated users in
@@ -32,11 +39,21 @@ func Middleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			// create user and check if user exists in db
-			if !user.Exist() {
+			// create user and check if user exists in db, service principal is validated by token itself
+			if !user.IsService() && !user.Exist() {
 				next.ServeHTTP(w, r)
 				return
 			}
+			// usage statistics should not block requests, so they are buffered and written periodically
+			if user.ApiKeyID != "" {
+				apiKeyUsage.track(user.ID, user.ApiKeyID, ClientIP(r), time.Now().Unix())
+			}
+			if user.ServiceTokenID != "" {
+				serviceTokenUsage.track(user.ServiceTokenID, time.Now().Unix())
+			}
+
+			user.IP = ClientIP(r)
+
 			// put it in context
 			ctx := context.WithValue(r.Context(), userCtxKey, &user)
 
@@ -47,6 +64,59 @@ func Middleware() func(http.Handler) http.Handler {
 	}
 }
 
+const (
+	// ProxyNone uses address of connection, headers are ignored
+	ProxyNone = "none"
+	// ProxyAppEngine uses X-Appengine-User-IP header which is set by App Engine frontend
+	ProxyAppEngine = "appengine"
+	// ProxyForwarded uses the last X-Forwarded-For hop which is appended by the trusted proxy
+	ProxyForwarded = "forwarded"
+)
+
+// TrustedProxy selects proxy header used for client address,
+// headers of other proxies are client supplied and could be forged
+var TrustedProxy = ProxyNone
+
+// SetTrustedProxy validates and sets proxy mode
+func SetTrustedProxy(mode string) error {
+	switch mode {
+	case ProxyNone, ProxyAppEngine, ProxyForwarded:
+		TrustedProxy = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown trusted proxy %q, expected %s, %s or %s", mode, ProxyNone, ProxyAppEngine, ProxyForwarded)
+	}
+}
+
+// ClientIP returns address of the client without port, proxy header is used only for TrustedProxy
+func ClientIP(r *http.Request) string {
+	switch TrustedProxy {
+	case ProxyAppEngine:
+		if ip := strings.TrimSpace(r.Header.Get("X-Appengine-User-IP")); ip != "" {
+			return ip
+		}
+	case ProxyForwarded:
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// IPForContext finds the client address from the context. REQUIRES Middleware to have run.
+func IPForContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ipCtxKey).(string)
+	return ip
+}
+
 // ForContext finds the user from the context. REQUIRES Middleware to have run.
 func ForContext(ctx context.Context) *users.User {
 	raw, _ := ctx.Value(userCtxKey).(*users.User)
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/mailer"
+	"github.com/Shelex/split-specs/storage"
+)
+
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset sends single-use reset token to user email,
+// unknown email is not reported to avoid disclosing registered users
+func RequestPasswordReset(sender mailer.Mailer, email string) error {
+	dbUser, err := storage.DB.GetUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	expireAt := time.Now().Add(passwordResetTTL)
+
+	if err := storage.DB.CreatePasswordReset(entities.PasswordReset{
+		ID:       hashToken(token),
+		UserID:   dbUser.ID,
+		ExpireAt: expireAt.Unix(),
+	}); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Somebody requested password reset for your split-specs account.\n\n"+
+			"Use this token with resetPassword mutation before %s:\n\n%s\n\n"+
+			"If it was not you, just ignore this email.",
+		expireAt.UTC().Format(time.RFC1123), token,
+	)
+
+	if err := sender.Send(dbUser.Email, "split-specs password reset", body); err != nil {
+		log.Printf("failed to send password reset to %s: %s", dbUser.Email, err)
+	}
+	return nil
+}
+
+// PasswordResetID returns id of stored password reset for token sent to user
+func PasswordResetID(token string) string {
+	return hashToken(token)
+}
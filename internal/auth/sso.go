@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/internal/users"
+	"github.com/Shelex/split-specs/pkg/oidc"
+	"github.com/Shelex/split-specs/storage"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	ssoCookieName = "split-specs-sso"
+	ssoCookiePath = "/auth/oidc"
+	ssoCookieTTL  = 600
+)
+
+var (
+	ErrVerifiedEmailRequired = errors.New("sso account should have verified email")
+	ErrAccountExists         = errors.New("account with this email already exists, sign in with password")
+)
+
+// SSO serves OpenID Connect login endpoints,
+// signed in user receives the same access and refresh tokens as with password login.
+// Existing password accounts are linked by verified email only when linkAccounts is enabled for the issuer
+type SSO struct {
+	provider     *oidc.Provider
+	linkAccounts bool
+}
+
+func NewSSO(provider *oidc.Provider, linkAccounts bool) *SSO {
+	return &SSO{
+		provider:     provider,
+		linkAccounts: linkAccounts,
+	}
+}
+
+// Login redirects to provider login page, state, nonce and code verifier are kept in short-lived cookie
+func (s *SSO) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setSSOCookie(w, r, strings.Join([]string{state, nonce, verifier}, "."), ssoCookieTTL)
+
+	http.Redirect(w, r, s.provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// Callback exchanges authorization code for id token and redirects to web page with tokens in url fragment
+func (s *SSO) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	cookie, err := r.Cookie(ssoCookieName)
+	if err != nil {
+		http.Error(w, "sso login is expired, please try again", http.StatusBadRequest)
+		return
+	}
+	setSSOCookie(w, r, "", -1)
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || parts[0] != query.Get("state") {
+		http.Error(w, "sso state mismatch", http.StatusBadRequest)
+		return
+	}
+	nonce, verifier := parts[1], parts[2]
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "sso login failed: "+providerErr, http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := s.provider.Exchange(query.Get("code"), verifier, nonce)
+	if err != nil {
+		http.Error(w, "sso login failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.user(claims)
+	if err != nil {
+		http.Error(w, "sso login failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := IssueTokens(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fragment := url.Values{
+		"accessToken":  {tokens.AccessToken},
+		"refreshToken": {tokens.RefreshToken},
+		"expireAt":     {strconv.FormatInt(tokens.ExpireAt, 10)},
+	}
+	http.Redirect(w, r, "/#"+fragment.Encode(), http.StatusFound)
+}
+
+// user finds user by subject, links existing account with the same verified email when it is allowed
+// or provisions new user without password
+func (s *SSO) user(claims oidc.Claims) (users.User, error) {
+	issuer := s.provider.Issuer()
+
+	dbUser, err := storage.DB.GetUserByOIDCSubject(issuer, claims.Subject)
+	if err == nil {
+		return users.EntityUserToUser(*dbUser), nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return users.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return users.User{}, ErrVerifiedEmailRequired
+	}
+
+	if existing, err := storage.DB.GetUserByEmail(claims.Email); err == nil {
+		if !s.linkAccounts {
+			return users.User{}, ErrAccountExists
+		}
+		if err := storage.DB.LinkUserToOIDCSubject(existing.ID, issuer, claims.Subject); err != nil {
+			return users.User{}, err
+		}
+		return users.EntityUserToUser(*existing), nil
+	}
+
+	id, _ := gonanoid.New()
+
+	user := entities.User{
+		ID:          id,
+		Email:       claims.Email,
+		OIDCIssuer:  issuer,
+		OIDCSubject: claims.Subject,
+	}
+	if err := storage.DB.CreateUser(user); err != nil {
+		return users.User{}, err
+	}
+	return users.EntityUserToUser(user), nil
+}
+
+func setSSOCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoCookieName,
+		Value:    value,
+		Path:     ssoCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
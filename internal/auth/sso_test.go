@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/pkg/jwt"
+	"github.com/Shelex/split-specs/pkg/oidc"
+	"github.com/Shelex/split-specs/pkg/oidc/oidctest"
+	"github.com/Shelex/split-specs/storage"
+)
+
+const ssoClientID = "split-specs"
+
+// newSSO starts mock provider with empty in-memory storage and token signing key
+func newSSO(t *testing.T, linkAccounts bool) (*SSO, *oidctest.Server) {
+	t.Helper()
+
+	if _, err := storage.NewInMemStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.rsa"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := jwt.LoadKeys(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	server := oidctest.NewServer(ssoClientID)
+	t.Cleanup(server.Close)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer(),
+		ClientID:    ssoClientID,
+		RedirectURL: "http://localhost/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSSO(provider, linkAccounts), server
+}
+
+// ssoLogin goes through login, provider and callback, state could be changed before callback
+func ssoLogin(t *testing.T, sso *SSO, server *oidctest.Server, state string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	login := httptest.NewRecorder()
+	sso.Login(login, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	cookies := login.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected sso cookie, got %d cookies", len(cookies))
+	}
+
+	callbackURL, err := server.Authorize(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := callbackURL.Query()
+	if state != "" {
+		query.Set("state", state)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+	request.AddCookie(cookies[0])
+
+	callback := httptest.NewRecorder()
+	sso.Callback(callback, request)
+	return callback
+}
+
+func expectLoggedIn(t *testing.T, response *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if response.Code != http.StatusFound || !strings.HasPrefix(response.Header().Get("Location"), "/#accessToken=") {
+		t.Fatalf("expected redirect with tokens, got %d %s", response.Code, response.Body.String())
+	}
+}
+
+func TestSSOCallbackRejectsStateMismatch(t *testing.T) {
+	sso, server := newSSO(t, false)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	response := ssoLogin(t, sso, server, "forged-state")
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestSSOCallbackRejectsUnknownKeyID(t *testing.T) {
+	sso, server := newSSO(t, false)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+	server.SignWithKeyID("unknown-key")
+
+	response := ssoLogin(t, sso, server, "")
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestSSOCallbackRequiresVerifiedEmail(t *testing.T) {
+	sso, server := newSSO(t, false)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: false})
+
+	response := ssoLogin(t, sso, server, "")
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+	if _, err := storage.DB.GetUserByEmail("user@example.com"); err == nil {
+		t.Fatal("user with unverified email should not be created")
+	}
+}
+
+func TestSSOFirstAndReturningLogin(t *testing.T) {
+	sso, server := newSSO(t, false)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	expectLoggedIn(t, ssoLogin(t, sso, server, ""))
+
+	created, err := storage.DB.GetUserByOIDCSubject(server.Issuer(), "user-1")
+	if err != nil {
+		t.Fatalf("user should be created on first login: %s", err)
+	}
+	if created.Email != "user@example.com" || created.Password != "" {
+		t.Fatalf("unexpected user %+v", created)
+	}
+
+	// returning user is matched by subject even when email is changed by provider
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "renamed@example.com", EmailVerified: true})
+
+	expectLoggedIn(t, ssoLogin(t, sso, server, ""))
+
+	returning, err := storage.DB.GetUserByOIDCSubject(server.Issuer(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returning.ID != created.ID {
+		t.Fatalf("expected user %s, got %s", created.ID, returning.ID)
+	}
+	if _, err := storage.DB.GetUserByEmail("renamed@example.com"); err == nil {
+		t.Fatal("returning login should not create another user")
+	}
+}
+
+func TestSSODoesNotLinkExistingAccountByDefault(t *testing.T) {
+	sso, server := newSSO(t, false)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	if err := storage.DB.CreateUser(entities.User{ID: "existing", Email: "user@example.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	response := ssoLogin(t, sso, server, "")
+
+	if response.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, response.Code)
+	}
+	if _, err := storage.DB.GetUserByOIDCSubject(server.Issuer(), "user-1"); err == nil {
+		t.Fatal("existing account should not be linked")
+	}
+}
+
+func TestSSOLinksExistingAccountWhenEnabled(t *testing.T) {
+	sso, server := newSSO(t, true)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	if err := storage.DB.CreateUser(entities.User{ID: "existing", Email: "user@example.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectLoggedIn(t, ssoLogin(t, sso, server, ""))
+
+	linked, err := storage.DB.GetUserByOIDCSubject(server.Issuer(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.ID != "existing" {
+		t.Fatalf("expected existing account to be linked, got %s", linked.ID)
+	}
+}
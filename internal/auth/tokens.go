@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Shelex/split-specs/entities"
+	"github.com/Shelex/split-specs/internal/users"
+	"github.com/Shelex/split-specs/pkg/jwt"
+	"github.com/Shelex/split-specs/storage"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+
+// IssueTokens generates short-lived access token along with refresh token to renew it
+func IssueTokens(user users.User) (entities.AuthTokens, error) {
+	now := time.Now()
+	expireAt := now.Add(accessTokenTTL).Unix()
+
+	accessToken, err := jwt.GenerateToken(user, expireAt)
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return entities.AuthTokens{}, err
+	}
+
+	if err := storage.DB.CreateRefreshToken(entities.RefreshToken{
+		ID:        hashToken(refreshToken),
+		UserID:    user.ID,
+		CreatedAt: now.Unix(),
+		ExpireAt:  now.Add(refreshTokenTTL).Unix(),
+	}); err != nil {
+		return entities.AuthTokens{}, err
+	}
+
+	return entities.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpireAt:     expireAt,
+	}, nil
+}
+
+// RefreshTokens exchanges refresh token for new pair of tokens, refresh token could be used only once
+func RefreshTokens(refreshToken string) (entities.AuthTokens, error) {
+	stored, err := storage.DB.ConsumeRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return entities.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	if stored.ExpireAt < time.Now().Unix() {
+		return entities.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	dbUser, err := storage.DB.GetUserByID(stored.UserID)
+	if err != nil || stored.CreatedAt < dbUser.TokensRevokedAt {
+		return entities.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	return IssueTokens(users.EntityUserToUser(*dbUser))
+}
+
+func randomToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
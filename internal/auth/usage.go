@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shelex/split-specs/storage"
+)
+
+// usageBuffer collects api key usage in memory, so parallel requests with the same key
+// do not contend on writes of the same entity
+type usageBuffer struct {
+	mu   sync.Mutex
+	keys map[string]*keyUsage
+}
+
+type keyUsage struct {
+	userID string
+	ip     string
+	usedAt int64
+	count  int64
+}
+
+var apiKeyUsage = usageBuffer{keys: make(map[string]*keyUsage)}
+
+// serviceTokenUsage keeps last usage time of service tokens till flush
+var serviceTokenUsage = touchBuffer{tokens: make(map[string]int64)}
+
+func (b *usageBuffer) track(userID string, keyID string, ip string, usedAt int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage, ok := b.keys[keyID]
+	if !ok {
+		usage = &keyUsage{userID: userID}
+		b.keys[keyID] = usage
+	}
+	usage.ip = ip
+	usage.usedAt = usedAt
+	usage.count++
+}
+
+func (b *usageBuffer) take() map[string]*keyUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := b.keys
+	b.keys = make(map[string]*keyUsage)
+	return keys
+}
+
+type touchBuffer struct {
+	mu     sync.Mutex
+	tokens map[string]int64
+}
+
+func (b *touchBuffer) track(tokenID string, usedAt int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens[tokenID] = usedAt
+}
+
+func (b *touchBuffer) take() map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tokens := b.tokens
+	b.tokens = make(map[string]int64)
+	return tokens
+}
+
+// FlushUsage writes buffered usage of api keys and service tokens, one write per key or token,
+// failed writes are only logged as usage is not required to authorize requests
+func FlushUsage() {
+	for keyID, usage := range apiKeyUsage.take() {
+		if err := storage.DB.TrackApiKeyUsage(usage.userID, keyID, usage.ip, usage.usedAt, usage.count); err != nil {
+			log.Printf("failed to track usage of api key %s: %s", keyID, err)
+		}
+	}
+
+	for tokenID, usedAt := range serviceTokenUsage.take() {
+		if err := storage.DB.TouchServiceToken(tokenID, usedAt); err != nil {
+			log.Printf("failed to track usage of service token %s: %s", tokenID, err)
+		}
+	}
+}
+
+// RunUsageFlush writes buffered usage every interval
+func RunUsageFlush(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		FlushUsage()
+	}
+}
@@ -7,6 +7,7 @@ func UserToEntityUser(user User) entities.User {
 		Email:    user.Email,
 		Password: user.Password,
 		ID:       user.ID,
+		IP:       user.IP,
 	}
 }
 
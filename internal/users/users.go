@@ -3,14 +3,61 @@ package users
 import (
 	"net/mail"
 
+	"github.com/Shelex/split-specs/pkg/limiter"
 	"github.com/Shelex/split-specs/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Lockout delays password attempts for account after repeated failures
+var Lockout = limiter.NewLockout(limiter.NewInMemStore(), "lockout:", limiter.DefaultLockoutPolicy)
+
 type User struct {
 	ID       string
 	Email    string
 	Password string
+	// ApiKeyID is set when user is authenticated with api key instead of user token
+	ApiKeyID string
+	// ServiceTokenID is set when principal is authenticated with project service token
+	ServiceTokenID string
+	// Scopes of api key, nil means legacy api key with all scopes
+	Scopes []string
+	// Projects api key is restricted to, empty means all user projects
+	Projects []string
+	// IP is client address of current request
+	IP string
+}
+
+// IsApiKey reports whether user is authenticated with api key or project service token
+func (user *User) IsApiKey() bool {
+	return user.ApiKeyID != "" || user.IsService()
+}
+
+func (user *User) IsService() bool {
+	return user.ServiceTokenID != ""
+}
+
+func (user *User) HasScope(scope string) bool {
+	if !user.IsApiKey() || user.Scopes == nil {
+		return true
+	}
+	for _, granted := range user.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (user *User) CanAccessProject(projectID string) bool {
+	if !user.IsApiKey() || len(user.Projects) == 0 {
+		return true
+	}
+	for _, allowed := range user.Projects {
+		if allowed == projectID {
+			return true
+		}
+	}
+	return false
 }
 
 func (user *User) Create() error {
@@ -27,12 +74,22 @@ func (user *User) Create() error {
 	return nil
 }
 
-func (user *User) Authenticate() bool {
+// Authenticate checks password unless account is locked after previous failures,
+// unknown emails are locked as well so they could not be distinguished
+func (user *User) Authenticate() error {
+	if err := Lockout.Check(user.Email); err != nil {
+		return err
+	}
+
 	dbUser, err := storage.DB.GetUserByEmail(user.Email)
-	if err != nil {
-		return false
+	if err != nil || !CheckPasswordHash(user.Password, dbUser.Password) {
+		if err := Lockout.Fail(user.Email); err != nil {
+			return err
+		}
+		return &WrongEmailOrPasswordError{}
 	}
-	return CheckPasswordHash(user.Password, dbUser.Password)
+
+	return Lockout.Reset(user.Email)
 }
 
 func (user *User) Exist() bool {
@@ -42,21 +99,6 @@ func (user *User) Exist() bool {
 	return true
 }
 
-func (user *User) ChangePassword(password string, newPassword string) error {
-	dbUser, err := storage.DB.GetUserByEmail(user.Email)
-	if err != nil {
-		return &AccessDeniedError{}
-	}
-	if match := CheckPasswordHash(password, dbUser.Password); !match {
-		return &AccessDeniedError{}
-	}
-	hashedPassword, err := HashPassword(newPassword)
-	if err != nil {
-		return err
-	}
-	return storage.DB.UpdatePassword(user.ID, hashedPassword)
-}
-
 //ValidateEmail checks that address matches RFC 5322 spec
 func (user *User) EmailIsValid() bool {
 	_, err := mail.ParseAddress(user.Email)
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
@@ -13,6 +16,11 @@ import (
 	"github.com/Shelex/split-specs/api/graph/generated"
 	"github.com/Shelex/split-specs/domain"
 	"github.com/Shelex/split-specs/internal/auth"
+	"github.com/Shelex/split-specs/internal/users"
+	"github.com/Shelex/split-specs/pkg/jwt"
+	"github.com/Shelex/split-specs/pkg/limiter"
+	"github.com/Shelex/split-specs/pkg/mailer"
+	"github.com/Shelex/split-specs/pkg/oidc"
 	"github.com/Shelex/split-specs/storage"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -32,7 +40,16 @@ func startMessage(port string) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := Command(os.Args[1], os.Args[2:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := Start(); err != nil {
+		log.Println(err)
 		os.Exit(1)
 	}
 }
@@ -44,6 +61,10 @@ func Start() error {
 		log.Printf("Defaulting to port %s", port)
 	}
 
+	if err := jwt.LoadKeysFromEnv(); err != nil {
+		return fmt.Errorf("failed to load jwt keys: %s", err)
+	}
+
 	db, err := InitDb()
 	if err != nil {
 		return fmt.Errorf("failed to initialize db: %s", err)
@@ -51,6 +72,23 @@ func Start() error {
 
 	svc := domain.NewSplitService(db)
 
+	if err := PurgeDeleted(&svc); err != nil {
+		return fmt.Errorf("failed to configure purge of deleted items: %s", err)
+	}
+
+	if err := Retention(&svc); err != nil {
+		return fmt.Errorf("failed to configure retention policies: %s", err)
+	}
+
+	if err := ClientAddress(); err != nil {
+		return fmt.Errorf("failed to configure client address: %s", err)
+	}
+
+	sender, err := mailer.FromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mailer: %s", err)
+	}
+
 	router := chi.NewRouter()
 	router.Use(auth.Middleware(), middleware.Logger)
 
@@ -65,15 +103,26 @@ func Start() error {
 	}))
 
 	gql := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{
-		Resolvers: graph.NewResolver(svc),
+		Resolvers: graph.NewResolver(svc, sender),
 	}))
 
+	if err := RateLimits(gql); err != nil {
+		return fmt.Errorf("failed to configure rate limits: %s", err)
+	}
+
 	gql.SetRecoverFunc(func(ctx context.Context, err interface{}) error {
 		return fmt.Errorf("internal server error: %s", err)
 	})
 
 	router.Handle("/query", (gql))
 	router.Handle("/playground", playground.Handler("GraphQL playground", "/query"))
+	router.Get("/.well-known/jwks.json", jwt.JWKSHandler)
+
+	if err := SSO(router); err != nil {
+		return fmt.Errorf("failed to initialize sso: %s", err)
+	}
+
+	Metrics(router)
 
 	FileServer(router)
 
@@ -105,6 +154,165 @@ func InitDb() (storage.Storage, error) {
 	return repo, nil
 }
 
+// PurgeDeleted starts background job removing soft deleted sessions and projects
+// after DELETED_RETENTION period, job runs every PURGE_INTERVAL
+func PurgeDeleted(svc *domain.SplitService) error {
+	retention, err := durationFromEnv("DELETED_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	interval, err := durationFromEnv("PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+
+	go svc.RunPurge(retention, interval)
+	return nil
+}
+
+// Retention starts background job purging sessions by retention policies of projects every RETENTION_INTERVAL
+func Retention(svc *domain.SplitService) error {
+	interval, err := durationFromEnv("RETENTION_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+
+	go svc.RunRetention(interval)
+	return nil
+}
+
+// ClientAddress configures proxy trusted to pass client address with TRUSTED_PROXY,
+// App Engine frontend is trusted by default when running on App Engine.
+// Usage of api keys and service tokens is buffered and written every API_KEY_USAGE_INTERVAL
+func ClientAddress() error {
+	proxy := os.Getenv("TRUSTED_PROXY")
+	if proxy == "" {
+		proxy = auth.ProxyNone
+		if os.Getenv("GAE_ENV") != "" {
+			proxy = auth.ProxyAppEngine
+		}
+	}
+
+	if err := auth.SetTrustedProxy(proxy); err != nil {
+		return err
+	}
+
+	interval, err := durationFromEnv("API_KEY_USAGE_INTERVAL", time.Minute)
+	if err != nil {
+		return err
+	}
+
+	go auth.RunUsageFlush(interval)
+	return nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q, expected positive duration like 720h", name, value)
+	}
+	return duration, nil
+}
+
+// RateLimits protects credential mutations from brute-force and limits operations per api key or user,
+// limits are configured with env variables
+func RateLimits(gql *handler.Server) error {
+	store := limiter.NewInMemStore()
+
+	perIP, err := limiter.RateFromEnv("LOGIN_RATE_PER_IP", limiter.Rate{Limit: 20, Window: time.Minute})
+	if err != nil {
+		return err
+	}
+
+	perAccount, err := limiter.RateFromEnv("LOGIN_RATE_PER_ACCOUNT", limiter.Rate{Limit: 10, Window: time.Minute})
+	if err != nil {
+		return err
+	}
+
+	policy, err := limiter.LockoutPolicyFromEnv(limiter.DefaultLockoutPolicy)
+	if err != nil {
+		return err
+	}
+
+	users.Lockout = limiter.NewLockout(store, "lockout:", policy)
+	gql.AroundFields(graph.CredentialRateLimit(
+		limiter.New(store, "ip:", perIP),
+		limiter.New(store, "account:", perAccount),
+	))
+
+	quotas := graph.Quotas{Operations: map[string]*limiter.TokenBucket{}}
+
+	defaultRate, err := limiter.RateFromEnv("QUOTA_DEFAULT", limiter.Rate{Limit: 300, Window: time.Minute})
+	if err != nil {
+		return err
+	}
+	quotas.Default = limiter.NewTokenBucket(store, "quota:", defaultRate)
+
+	operationRates := map[string]struct {
+		env      string
+		fallback limiter.Rate
+	}{
+		"nextSpec":   {"QUOTA_NEXT_SPEC", limiter.Rate{Limit: 600, Window: time.Minute}},
+		"addSession": {"QUOTA_ADD_SESSION", limiter.Rate{Limit: 30, Window: time.Minute}},
+	}
+	for operation, config := range operationRates {
+		rate, err := limiter.RateFromEnv(config.env, config.fallback)
+		if err != nil {
+			return err
+		}
+		quotas.Operations[operation] = limiter.NewTokenBucket(store, "quota:", rate)
+	}
+
+	gql.AroundFields(graph.OperationQuota(quotas))
+	return nil
+}
+
+// SSO enables OpenID Connect login when OIDC_ISSUER is set
+func SSO(router *chi.Mux) error {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	})
+	if err != nil {
+		return err
+	}
+
+	sso := auth.NewSSO(provider, os.Getenv("OIDC_LINK_EXISTING_ACCOUNTS") == "true")
+	router.Get("/auth/oidc/login", sso.Login)
+	router.Get("/auth/oidc/callback", sso.Callback)
+	return nil
+}
+
+// Metrics publishes expvar counters (like auditFailures) on /debug/vars when METRICS_TOKEN is set,
+// token is passed with X-Metrics-Token header
+func Metrics(router *chi.Mux) {
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		return
+	}
+
+	router.Get("/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Metrics-Token")), []byte(token)) != 1 {
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+		expvar.Handler().ServeHTTP(w, r)
+	})
+}
+
 // FileServer is serving static folder built from web page sources
 func FileServer(router *chi.Mux) {
 	root := "./web/build"
@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version of archive format, archives of newer versions could not be read
+const Version = 1
+
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+var ErrUnknownFormat = errors.New("unknown archive format, expected json or ndjson")
+
+// Archive is portable copy of project data, it does not depend on ids of the instance it was exported from
+type Archive struct {
+	Version    int        `json:"version"`
+	ExportedAt int64      `json:"exportedAt"`
+	Project    Project    `json:"project"`
+	Members    []Member   `json:"members"`
+	Sessions   []Session  `json:"sessions"`
+	Baselines  []Baseline `json:"baselines,omitempty"`
+}
+
+type Project struct {
+	Name                string `json:"name"`
+	Organisation        string `json:"organisation,omitempty"`
+	RetentionKeepLast   int    `json:"retentionKeepLast,omitempty"`
+	RetentionMaxAgeDays int    `json:"retentionMaxAgeDays,omitempty"`
+	// EstimationSource is name of project used for estimation, it is matched by name on import
+	EstimationSource string `json:"estimationSource,omitempty"`
+	// EstimationKeys are label keys used to restrict estimation history
+	EstimationKeys []string `json:"estimationKeys,omitempty"`
+}
+
+// Member is matched by email on import
+type Member struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type Session struct {
+	ID                 string  `json:"id"`
+	Start              int64   `json:"start"`
+	End                int64   `json:"end"`
+	AbortAfterFailures int     `json:"abortAfterFailures,omitempty"`
+	AbortedBy          string  `json:"abortedBy,omitempty"`
+	Labels             []Label `json:"labels,omitempty"`
+	Specs              []Spec  `json:"specs,omitempty"`
+}
+
+type Label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Baseline is imported duration of spec file in seconds
+type Baseline struct {
+	FilePath string `json:"filePath"`
+	Duration int64  `json:"duration"`
+}
+
+type Spec struct {
+	FilePath          string   `json:"filePath"`
+	Tests             []string `json:"tests,omitempty"`
+	EstimatedDuration int64    `json:"estimatedDuration"`
+	Start             int64    `json:"start"`
+	End               int64    `json:"end"`
+	Passed            bool     `json:"passed"`
+	Skipped           bool     `json:"skipped,omitempty"`
+	AssignedTo        string   `json:"assignedTo,omitempty"`
+}
+
+func IsValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatNDJSON
+}
+
+func checkVersion(version int) error {
+	if version < 1 || version > Version {
+		return fmt.Errorf("unsupported archive version %d, expected up to %d", version, Version)
+	}
+	return nil
+}
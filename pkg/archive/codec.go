@@ -0,0 +1,148 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// record is single line of ndjson archive, the first line is always project record
+type record struct {
+	Type       string    `json:"type"`
+	Version    int       `json:"version,omitempty"`
+	ExportedAt int64     `json:"exportedAt,omitempty"`
+	Project    *Project  `json:"project,omitempty"`
+	Member     *Member   `json:"member,omitempty"`
+	Session    *Session  `json:"session,omitempty"`
+	SessionID  string    `json:"sessionId,omitempty"`
+	Spec       *Spec     `json:"spec,omitempty"`
+	Baseline   *Baseline `json:"baseline,omitempty"`
+}
+
+const (
+	recordProject  = "project"
+	recordMember   = "member"
+	recordSession  = "session"
+	recordSpec     = "spec"
+	recordBaseline = "baseline"
+)
+
+// Write encodes archive as single json document or as ndjson with one record per line,
+// ndjson is preferred for big projects as it could be processed line by line
+func Write(w io.Writer, archive Archive, format string) error {
+	encoder := json.NewEncoder(w)
+
+	switch format {
+	case FormatJSON:
+		return encoder.Encode(archive)
+	case FormatNDJSON:
+	default:
+		return ErrUnknownFormat
+	}
+
+	project := archive.Project
+	if err := encoder.Encode(record{Type: recordProject, Version: archive.Version, ExportedAt: archive.ExportedAt, Project: &project}); err != nil {
+		return err
+	}
+
+	for index := range archive.Members {
+		if err := encoder.Encode(record{Type: recordMember, Member: &archive.Members[index]}); err != nil {
+			return err
+		}
+	}
+
+	for index := range archive.Baselines {
+		if err := encoder.Encode(record{Type: recordBaseline, Baseline: &archive.Baselines[index]}); err != nil {
+			return err
+		}
+	}
+
+	for _, session := range archive.Sessions {
+		specs := session.Specs
+		session.Specs = nil
+
+		if err := encoder.Encode(record{Type: recordSession, Session: &session}); err != nil {
+			return err
+		}
+
+		for index := range specs {
+			if err := encoder.Encode(record{Type: recordSpec, SessionID: session.ID, Spec: &specs[index]}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Read decodes archive in any of supported formats, format is detected by the first json value
+func Read(r io.Reader) (Archive, error) {
+	decoder := json.NewDecoder(r)
+
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return Archive{}, fmt.Errorf("failed to read archive: %s", err)
+	}
+
+	var header record
+	if err := json.Unmarshal(first, &header); err != nil {
+		return Archive{}, fmt.Errorf("failed to read archive: %s", err)
+	}
+
+	if header.Type != recordProject {
+		var archive Archive
+		if err := json.Unmarshal(first, &archive); err != nil {
+			return Archive{}, fmt.Errorf("failed to read archive: %s", err)
+		}
+		if err := checkVersion(archive.Version); err != nil {
+			return Archive{}, err
+		}
+		return archive, nil
+	}
+
+	return readRecords(decoder, header)
+}
+
+func readRecords(decoder *json.Decoder, header record) (Archive, error) {
+	if err := checkVersion(header.Version); err != nil {
+		return Archive{}, err
+	}
+
+	archive := Archive{
+		Version:    header.Version,
+		ExportedAt: header.ExportedAt,
+	}
+	if header.Project != nil {
+		archive.Project = *header.Project
+	}
+
+	sessions := make(map[string]int)
+
+	for line := 2; ; line++ {
+		var item record
+		err := decoder.Decode(&item)
+		if err == io.EOF {
+			return archive, nil
+		}
+		if err != nil {
+			return Archive{}, fmt.Errorf("failed to read archive record %d: %s", line, err)
+		}
+
+		switch {
+		case item.Type == recordMember && item.Member != nil:
+			archive.Members = append(archive.Members, *item.Member)
+		case item.Type == recordBaseline && item.Baseline != nil:
+			archive.Baselines = append(archive.Baselines, *item.Baseline)
+		case item.Type == recordSession && item.Session != nil:
+			sessions[item.Session.ID] = len(archive.Sessions)
+			archive.Sessions = append(archive.Sessions, *item.Session)
+		case item.Type == recordSpec && item.Spec != nil:
+			index, ok := sessions[item.SessionID]
+			if !ok {
+				return Archive{}, fmt.Errorf("archive record %d: spec of unknown session %s", line, item.SessionID)
+			}
+			archive.Sessions[index].Specs = append(archive.Sessions[index].Specs, *item.Spec)
+		default:
+			return Archive{}, fmt.Errorf("archive record %d: unknown record type %q", line, item.Type)
+		}
+	}
+}
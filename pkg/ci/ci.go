@@ -0,0 +1,119 @@
+package ci
+
+import (
+	"strings"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+const (
+	GitHubActions = "github"
+	GitLab        = "gitlab"
+	Jenkins       = "jenkins"
+	CircleCI      = "circleci"
+	Buildkite     = "buildkite"
+)
+
+// Detect reads metadata of current CI build from environment variables of supported providers,
+// returns false when process is not running in any of them
+func Detect(getenv func(string) string) (entities.CIMetadata, bool) {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return github(getenv), true
+	case getenv("GITLAB_CI") != "":
+		return gitlab(getenv), true
+	case getenv("BUILDKITE") == "true":
+		return buildkite(getenv), true
+	case getenv("CIRCLECI") == "true":
+		return circleci(getenv), true
+	case getenv("JENKINS_URL") != "":
+		return jenkins(getenv), true
+	default:
+		return entities.CIMetadata{}, false
+	}
+}
+
+func github(getenv func(string) string) entities.CIMetadata {
+	metadata := entities.CIMetadata{
+		Provider: GitHubActions,
+		RunID:    getenv("GITHUB_RUN_ID"),
+		Commit:   getenv("GITHUB_SHA"),
+		Branch:   firstOf(getenv("GITHUB_HEAD_REF"), getenv("GITHUB_REF_NAME")),
+	}
+
+	// pull request ref looks like refs/pull/42/merge
+	if ref := getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/pull/") {
+		metadata.PullRequest = strings.Split(strings.TrimPrefix(ref, "refs/pull/"), "/")[0]
+	}
+
+	if server, repository := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"); server != "" && repository != "" && metadata.RunID != "" {
+		metadata.JobURL = server + "/" + repository + "/actions/runs/" + metadata.RunID
+	}
+	return metadata
+}
+
+func gitlab(getenv func(string) string) entities.CIMetadata {
+	return entities.CIMetadata{
+		Provider:    GitLab,
+		RunID:       getenv("CI_PIPELINE_ID"),
+		Commit:      getenv("CI_COMMIT_SHA"),
+		Branch:      firstOf(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), getenv("CI_COMMIT_REF_NAME")),
+		PullRequest: getenv("CI_MERGE_REQUEST_IID"),
+		JobURL:      getenv("CI_JOB_URL"),
+	}
+}
+
+func jenkins(getenv func(string) string) entities.CIMetadata {
+	return entities.CIMetadata{
+		Provider:    Jenkins,
+		RunID:       getenv("BUILD_NUMBER"),
+		Commit:      getenv("GIT_COMMIT"),
+		Branch:      firstOf(getenv("CHANGE_BRANCH"), getenv("BRANCH_NAME"), strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")),
+		PullRequest: getenv("CHANGE_ID"),
+		JobURL:      getenv("BUILD_URL"),
+	}
+}
+
+func circleci(getenv func(string) string) entities.CIMetadata {
+	metadata := entities.CIMetadata{
+		Provider: CircleCI,
+		RunID:    firstOf(getenv("CIRCLE_WORKFLOW_ID"), getenv("CIRCLE_BUILD_NUM")),
+		Commit:   getenv("CIRCLE_SHA1"),
+		Branch:   getenv("CIRCLE_BRANCH"),
+		JobURL:   getenv("CIRCLE_BUILD_URL"),
+	}
+
+	// pull request url looks like https://github.com/org/repo/pull/42
+	if url := getenv("CIRCLE_PULL_REQUEST"); url != "" {
+		metadata.PullRequest = url[strings.LastIndex(url, "/")+1:]
+	}
+	return metadata
+}
+
+func buildkite(getenv func(string) string) entities.CIMetadata {
+	metadata := entities.CIMetadata{
+		Provider: Buildkite,
+		RunID:    getenv("BUILDKITE_BUILD_ID"),
+		Commit:   getenv("BUILDKITE_COMMIT"),
+		Branch:   getenv("BUILDKITE_BRANCH"),
+		JobURL:   getenv("BUILDKITE_BUILD_URL"),
+	}
+
+	if pullRequest := getenv("BUILDKITE_PULL_REQUEST"); pullRequest != "false" {
+		metadata.PullRequest = pullRequest
+	}
+
+	if jobID := getenv("BUILDKITE_JOB_ID"); jobID != "" && metadata.JobURL != "" {
+		metadata.JobURL += "#" + jobID
+	}
+	return metadata
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/Shelex/split-specs/entities"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		detected bool
+		expected entities.CIMetadata
+	}{
+		{
+			name: "no ci",
+			env:  map[string]string{"HOME": "/root", "GITHUB_ACTIONS": "false"},
+		},
+		{
+			name: "github actions pull request",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_RUN_ID":     "1001",
+				"GITHUB_SHA":        "abc123",
+				"GITHUB_HEAD_REF":   "feature",
+				"GITHUB_REF_NAME":   "42/merge",
+				"GITHUB_REF":        "refs/pull/42/merge",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "org/repo",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider:    GitHubActions,
+				RunID:       "1001",
+				Commit:      "abc123",
+				Branch:      "feature",
+				PullRequest: "42",
+				JobURL:      "https://github.com/org/repo/actions/runs/1001",
+			},
+		},
+		{
+			name: "github actions branch push",
+			env: map[string]string{
+				"GITHUB_ACTIONS":  "true",
+				"GITHUB_RUN_ID":   "1002",
+				"GITHUB_REF_NAME": "main",
+				"GITHUB_REF":      "refs/heads/main",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider: GitHubActions,
+				RunID:    "1002",
+				Branch:   "main",
+			},
+		},
+		{
+			name: "gitlab merge request",
+			env: map[string]string{
+				"GITLAB_CI":                           "true",
+				"CI_PIPELINE_ID":                      "2001",
+				"CI_COMMIT_SHA":                       "def456",
+				"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature",
+				"CI_COMMIT_REF_NAME":                  "refs/merge-requests/7/head",
+				"CI_MERGE_REQUEST_IID":                "7",
+				"CI_JOB_URL":                          "https://gitlab.com/org/repo/-/jobs/1",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider:    GitLab,
+				RunID:       "2001",
+				Commit:      "def456",
+				Branch:      "feature",
+				PullRequest: "7",
+				JobURL:      "https://gitlab.com/org/repo/-/jobs/1",
+			},
+		},
+		{
+			name: "buildkite",
+			env: map[string]string{
+				"BUILDKITE":              "true",
+				"BUILDKITE_BUILD_ID":     "3001",
+				"BUILDKITE_COMMIT":       "fed789",
+				"BUILDKITE_BRANCH":       "main",
+				"BUILDKITE_BUILD_URL":    "https://buildkite.com/org/pipeline/builds/5",
+				"BUILDKITE_JOB_ID":       "job-1",
+				"BUILDKITE_PULL_REQUEST": "false",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider: Buildkite,
+				RunID:    "3001",
+				Commit:   "fed789",
+				Branch:   "main",
+				JobURL:   "https://buildkite.com/org/pipeline/builds/5#job-1",
+			},
+		},
+		{
+			name: "circleci",
+			env: map[string]string{
+				"CIRCLECI":            "true",
+				"CIRCLE_WORKFLOW_ID":  "workflow-1",
+				"CIRCLE_BUILD_NUM":    "4001",
+				"CIRCLE_SHA1":         "aaa111",
+				"CIRCLE_BRANCH":       "feature",
+				"CIRCLE_BUILD_URL":    "https://circleci.com/gh/org/repo/4001",
+				"CIRCLE_PULL_REQUEST": "https://github.com/org/repo/pull/12",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider:    CircleCI,
+				RunID:       "workflow-1",
+				Commit:      "aaa111",
+				Branch:      "feature",
+				PullRequest: "12",
+				JobURL:      "https://circleci.com/gh/org/repo/4001",
+			},
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL":  "https://jenkins.example.com/",
+				"BUILD_NUMBER": "5001",
+				"GIT_COMMIT":   "bbb222",
+				"GIT_BRANCH":   "origin/develop",
+				"BUILD_URL":    "https://jenkins.example.com/job/repo/5001/",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider: Jenkins,
+				RunID:    "5001",
+				Commit:   "bbb222",
+				Branch:   "develop",
+				JobURL:   "https://jenkins.example.com/job/repo/5001/",
+			},
+		},
+		{
+			name: "jenkins multibranch pull request",
+			env: map[string]string{
+				"JENKINS_URL":   "https://jenkins.example.com/",
+				"BUILD_NUMBER":  "5002",
+				"CHANGE_ID":     "13",
+				"CHANGE_BRANCH": "feature",
+				"BRANCH_NAME":   "PR-13",
+			},
+			detected: true,
+			expected: entities.CIMetadata{
+				Provider:    Jenkins,
+				RunID:       "5002",
+				Branch:      "feature",
+				PullRequest: "13",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata, detected := Detect(func(key string) string {
+				return test.env[key]
+			})
+			if detected != test.detected {
+				t.Fatalf("expected detected %t, got %t", test.detected, detected)
+			}
+			if metadata != test.expected {
+				t.Fatalf("expected %+v, got %+v", test.expected, metadata)
+			}
+		})
+	}
+}
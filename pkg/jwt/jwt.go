@@ -1,10 +1,7 @@
 package jwt
 
 import (
-	"crypto/rsa"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"time"
 
 	"github.com/Shelex/split-specs/entities"
@@ -13,35 +10,7 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-const (
-	privKeyPath = "keys/app.rsa"     // openssl genrsa -out app.rsa keysize
-	pubKeyPath  = "keys/app.rsa.pub" // openssl rsa -in app.rsa -pubout > app.rsa.pub
-)
-
-var (
-	verifyKey *rsa.PublicKey
-	signKey   *rsa.PrivateKey
-)
-
-func init() {
-	signBytes, err := ioutil.ReadFile(privKeyPath)
-	fatal(err)
-
-	signKey, err = jwt.ParseRSAPrivateKeyFromPEM(signBytes)
-	fatal(err)
-
-	verifyBytes, err := ioutil.ReadFile(pubKeyPath)
-	fatal(err)
-
-	verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(verifyBytes)
-	fatal(err)
-}
-
-func fatal(err error) {
-	if err != nil {
-		log.Fatal(err)
-	}
-}
+const serviceEntity = "service"
 
 //data we save in each token
 type Claims struct {
@@ -53,8 +22,8 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
-//GenerateToken generates a jwt token and assign an email to it's claims and return it
-func GenerateToken(user users.User) (string, error) {
+//GenerateToken generates a short-lived jwt token and assign an email to it's claims and return it
+func GenerateToken(user users.User, expireAt int64) (string, error) {
 	token := jwt.New(jwt.SigningMethodRS256)
 	/* Create a map to store our claims */
 	claims := token.Claims.(jwt.MapClaims)
@@ -62,10 +31,10 @@ func GenerateToken(user users.User) (string, error) {
 	claims["email"] = user.Email
 	claims["id"] = user.ID
 	claims["entity"] = "user"
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-	tokenString, err := token.SignedString(signKey)
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = expireAt
+	tokenString, err := sign(token)
 	if err != nil {
-		log.Fatal("Error in Generating key")
 		return "", err
 	}
 	return tokenString, nil
@@ -81,9 +50,29 @@ func GenerateApiKey(user users.User, apiKey entities.ApiKey) (string, error) {
 	claims["id"] = user.ID
 	claims["entity"] = apiKey.ID
 	claims["exp"] = apiKey.ExpireAt
-	tokenString, err := token.SignedString(signKey)
+	claims["scopes"] = apiKey.Scopes
+	claims["projects"] = apiKey.Projects
+	tokenString, err := sign(token)
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+//GenerateServiceToken generates a jwt token for project service principal
+func GenerateServiceToken(serviceToken entities.ServiceToken) (string, error) {
+	token := jwt.New(jwt.SigningMethodRS256)
+	/* Create a map to store our claims */
+	claims := token.Claims.(jwt.MapClaims)
+	/* Set token claims */
+	claims["email"] = entities.ServicePrincipalPrefix + serviceToken.Name
+	claims["id"] = serviceToken.ID
+	claims["entity"] = serviceEntity
+	claims["project"] = serviceToken.ProjectID
+	claims["scopes"] = serviceToken.Scopes
+	claims["exp"] = serviceToken.ExpireAt
+	tokenString, err := sign(token)
 	if err != nil {
-		log.Fatal("Error in Generating key")
 		return "", err
 	}
 	return tokenString, nil
@@ -91,9 +80,7 @@ func GenerateApiKey(user users.User, apiKey entities.ApiKey) (string, error) {
 
 //ParseToken parses a jwt token and returns the email it claims
 func ParseToken(tokenStr string) (users.User, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return verifyKey, nil
-	})
+	token, err := jwt.Parse(tokenStr, verifyKey)
 
 	empty := users.User{}
 
@@ -110,6 +97,16 @@ func ParseToken(tokenStr string) (users.User, error) {
 
 		entity := claims["entity"].(string)
 
+		if entity == serviceEntity {
+			return parseServiceToken(claims)
+		}
+
+		if entity == "user" {
+			if err := checkRevoked(user.ID, claims); err != nil {
+				return empty, err
+			}
+		}
+
 		if entity != "user" {
 			isValid := false
 			apiKeys, err := storage.DB.GetApiKeys(user.ID)
@@ -127,9 +124,62 @@ func ParseToken(tokenStr string) (users.User, error) {
 			if !isValid {
 				return empty, fmt.Errorf("api key is invalid")
 			}
+
+			user.ApiKeyID = entity
+			user.Projects = claimToStrings(claims["projects"])
+
+			// api keys issued before scopes were introduced have no such claim and keep all scopes
+			if _, ok := claims["scopes"]; ok {
+				user.Scopes = claimToStrings(claims["scopes"])
+			}
 		}
 
 		return user, nil
 	}
 	return empty, fmt.Errorf("could not parse claims from jwt token")
 }
+
+// checkRevoked rejects user token issued before user has revoked his tokens
+func checkRevoked(userID string, claims jwt.MapClaims) error {
+	dbUser, err := storage.DB.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	// tokens issued before revocation was introduced have no iat claim
+	issuedAt, _ := claims["iat"].(float64)
+	if int64(issuedAt) < dbUser.TokensRevokedAt {
+		return fmt.Errorf("token is revoked")
+	}
+	return nil
+}
+
+func claimToStrings(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
+
+func parseServiceToken(claims jwt.MapClaims) (users.User, error) {
+	tokenID, _ := claims["id"].(string)
+	projectID, _ := claims["project"].(string)
+
+	serviceToken, err := storage.DB.GetServiceToken(tokenID)
+	if err != nil || serviceToken.ProjectID != projectID {
+		return users.User{}, fmt.Errorf("service token is invalid")
+	}
+
+	return users.User{
+		ID:             entities.ServicePrincipalPrefix + tokenID,
+		Email:          entities.ServicePrincipalPrefix + serviceToken.Name,
+		ServiceTokenID: tokenID,
+		Scopes:         claimToStrings(claims["scopes"]),
+		Projects:       []string{projectID},
+	}, nil
+}
@@ -0,0 +1,192 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	defaultKeysDir  = "keys"
+	privateKeyExt   = ".rsa"     // openssl genrsa -out <kid>.rsa keysize
+	publicKeyExt    = ".rsa.pub" // openssl rsa -in <kid>.rsa -pubout > <kid>.rsa.pub
+	signingKeyIDEnv = "JWT_SIGNING_KEY"
+	keysDirEnv      = "JWT_KEYS_DIR"
+)
+
+// keySet holds every key accepted for verification and the one used for signing,
+// retired keys stay in set until tokens signed with them are expired
+type keySet struct {
+	signingID  string
+	signingKey *rsa.PrivateKey
+	verifyKeys map[string]*rsa.PublicKey
+}
+
+var keys keySet
+
+// LoadKeysFromEnv loads keys from JWT_KEYS_DIR ("keys" by default) and signs tokens with JWT_SIGNING_KEY
+func LoadKeysFromEnv() error {
+	dir := os.Getenv(keysDirEnv)
+	if dir == "" {
+		dir = defaultKeysDir
+	}
+	return LoadKeys(dir, os.Getenv(signingKeyIDEnv))
+}
+
+// LoadKeys reads "<kid>.rsa" private and "<kid>.rsa.pub" public keys from directory,
+// public-only keys are used to verify tokens of retired keys.
+// Signing key id could be omitted when directory has single private key
+func LoadKeys(dir string, signingID string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	loaded := keySet{verifyKeys: map[string]*rsa.PublicKey{}}
+	privateKeys := map[string]*rsa.PrivateKey{}
+
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(dir, name)
+
+		switch {
+		case strings.HasSuffix(name, publicKeyExt):
+			kid := strings.TrimSuffix(name, publicKeyExt)
+			key, err := readPublicKey(path)
+			if err != nil {
+				return fmt.Errorf("failed to read key %s: %w", kid, err)
+			}
+			loaded.verifyKeys[kid] = key
+		case strings.HasSuffix(name, privateKeyExt):
+			kid := strings.TrimSuffix(name, privateKeyExt)
+			key, err := readPrivateKey(path)
+			if err != nil {
+				return fmt.Errorf("failed to read key %s: %w", kid, err)
+			}
+			privateKeys[kid] = key
+		}
+	}
+
+	for kid, key := range privateKeys {
+		loaded.verifyKeys[kid] = &key.PublicKey
+	}
+
+	if signingID == "" {
+		if len(privateKeys) != 1 {
+			return fmt.Errorf("%s should be set when there is not exactly one private key", signingKeyIDEnv)
+		}
+		for kid := range privateKeys {
+			signingID = kid
+		}
+	}
+
+	signingKey, ok := privateKeys[signingID]
+	if !ok {
+		return fmt.Errorf("private key %s not found in %s", signingID, dir)
+	}
+
+	loaded.signingID = signingID
+	loaded.signingKey = signingKey
+	keys = loaded
+	return nil
+}
+
+func readPrivateKey(path string) (*rsa.PrivateKey, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM(bytes)
+}
+
+func readPublicKey(path string) (*rsa.PublicKey, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(bytes)
+}
+
+// sign signs token with current signing key and marks it with key id
+func sign(token *jwt.Token) (string, error) {
+	if keys.signingKey == nil {
+		return "", fmt.Errorf("signing key is not loaded")
+	}
+	token.Header["kid"] = keys.signingID
+	return token.SignedString(keys.signingKey)
+}
+
+// verifyKey finds key token was signed with,
+// tokens issued before key ids were introduced have no kid and are checked with every key
+func verifyKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+	}
+
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok := keys.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %s", kid)
+		}
+		return key, nil
+	}
+
+	parts := strings.Split(token.Raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is malformed")
+	}
+	for _, key := range keys.verifyKeys {
+		if err := token.Method.Verify(parts[0]+"."+parts[1], parts[2], key); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("token is not signed with known key")
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSHandler serves public keys in JSON Web Key Set format for services verifying our tokens
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	kids := make([]string, 0, len(keys.verifyKeys))
+	for kid := range keys.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: make([]jwk, 0, len(kids))}
+
+	for _, kid := range kids {
+		key := keys.verifyKeys[kid]
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(set)
+}
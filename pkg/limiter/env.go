@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateFromEnv parses rate in "<limit>/<window>" format, for example "20/1m"
+func RateFromEnv(name string, fallback Rate) (Rate, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Rate{}, fmt.Errorf("%s should be in <limit>/<window> format", name)
+	}
+
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil || limit < 1 {
+		return Rate{}, fmt.Errorf("%s has invalid limit %s", name, parts[0])
+	}
+
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return Rate{}, fmt.Errorf("%s has invalid window %s", name, parts[1])
+	}
+
+	return Rate{Limit: limit, Window: window}, nil
+}
+
+// LockoutPolicyFromEnv reads LOGIN_LOCKOUT_THRESHOLD, LOGIN_LOCKOUT_DELAY and LOGIN_LOCKOUT_MAX_DELAY
+func LockoutPolicyFromEnv(fallback LockoutPolicy) (LockoutPolicy, error) {
+	policy := fallback
+
+	if value := os.Getenv("LOGIN_LOCKOUT_THRESHOLD"); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 1 {
+			return policy, fmt.Errorf("LOGIN_LOCKOUT_THRESHOLD has invalid value %s", value)
+		}
+		policy.Threshold = threshold
+	}
+
+	if value := os.Getenv("LOGIN_LOCKOUT_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay <= 0 {
+			return policy, fmt.Errorf("LOGIN_LOCKOUT_DELAY has invalid value %s", value)
+		}
+		policy.Delay = delay
+	}
+
+	if value := os.Getenv("LOGIN_LOCKOUT_MAX_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay <= 0 {
+			return policy, fmt.Errorf("LOGIN_LOCKOUT_MAX_DELAY has invalid value %s", value)
+		}
+		policy.MaxDelay = delay
+	}
+
+	if policy.Window < policy.MaxDelay {
+		policy.Window = policy.MaxDelay
+	}
+	return policy, nil
+}
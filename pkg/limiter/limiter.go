@@ -0,0 +1,163 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// LimitExceededError is returned when key should wait before next attempt
+type LimitExceededError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %d seconds", e.RetryAfterSeconds())
+}
+
+func (e *LimitExceededError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Extensions are added to graphql error, so clients could wait before retry
+func (e *LimitExceededError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":       "RATE_LIMITED",
+		"retryAfter": e.RetryAfterSeconds(),
+	}
+}
+
+// Rate allows Limit hits per Window
+type Rate struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Limiter is fixed window rate limiter
+type Limiter struct {
+	store  Store
+	prefix string
+	rate   Rate
+}
+
+func New(store Store, prefix string, rate Rate) *Limiter {
+	return &Limiter{
+		store:  store,
+		prefix: prefix,
+		rate:   rate,
+	}
+}
+
+// Allow registers hit of key and returns LimitExceededError when rate is exceeded
+func (l *Limiter) Allow(key string) error {
+	now := time.Now()
+
+	entry, err := l.store.Increment(l.prefix+key, l.rate.Window, now)
+	if err != nil {
+		return err
+	}
+
+	if entry.Count > l.rate.Limit {
+		return &LimitExceededError{RetryAfter: entry.ExpireAt.Sub(now)}
+	}
+	return nil
+}
+
+// TokenBucket allows bursts up to rate limit and refills Limit tokens per Window
+type TokenBucket struct {
+	store  BucketStore
+	prefix string
+	rate   Rate
+}
+
+func NewTokenBucket(store BucketStore, prefix string, rate Rate) *TokenBucket {
+	return &TokenBucket{
+		store:  store,
+		prefix: prefix,
+		rate:   rate,
+	}
+}
+
+// Allow takes token from key bucket and returns LimitExceededError when bucket is empty
+func (b *TokenBucket) Allow(key string) error {
+	retryAfter, err := b.store.Take(b.prefix+key, b.rate, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if retryAfter > 0 {
+		return &LimitExceededError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// LockoutPolicy locks key for Delay after Threshold failures,
+// delay is doubled with every next failure up to MaxDelay.
+// Failures are forgotten after Window since first of them
+type LockoutPolicy struct {
+	Threshold int
+	Delay     time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	Threshold: 5,
+	Delay:     time.Minute,
+	MaxDelay:  time.Hour,
+	Window:    24 * time.Hour,
+}
+
+type Lockout struct {
+	store  Store
+	prefix string
+	policy LockoutPolicy
+}
+
+func NewLockout(store Store, prefix string, policy LockoutPolicy) *Lockout {
+	return &Lockout{
+		store:  store,
+		prefix: prefix,
+		policy: policy,
+	}
+}
+
+// Check returns LimitExceededError while key is locked
+func (l *Lockout) Check(key string) error {
+	now := time.Now()
+
+	entry, err := l.store.Get(l.prefix+key, now)
+	if err != nil {
+		return err
+	}
+
+	if entry.Count < l.policy.Threshold {
+		return nil
+	}
+
+	lockedUntil := entry.Last.Add(l.delay(entry.Count))
+	if now.Before(lockedUntil) {
+		return &LimitExceededError{RetryAfter: lockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+func (l *Lockout) Fail(key string) error {
+	_, err := l.store.Increment(l.prefix+key, l.policy.Window, time.Now())
+	return err
+}
+
+func (l *Lockout) Reset(key string) error {
+	return l.store.Reset(l.prefix + key)
+}
+
+func (l *Lockout) delay(failures int) time.Duration {
+	delay := l.policy.Delay
+	for i := l.policy.Threshold; i < failures && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		return l.policy.MaxDelay
+	}
+	return delay
+}
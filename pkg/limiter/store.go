@@ -0,0 +1,133 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Entry counts hits of key within window started at Start
+type Entry struct {
+	Count    int
+	Start    time.Time
+	Last     time.Time
+	ExpireAt time.Time
+}
+
+// Store keeps limiter state, could be shared by limiters with different key prefixes
+type Store interface {
+	Get(key string, now time.Time) (Entry, error)
+	// Increment adds hit to key, counter starts over when window of previous hits is passed
+	Increment(key string, window time.Duration, now time.Time) (Entry, error)
+	Reset(key string) error
+}
+
+// BucketStore keeps token buckets state
+type BucketStore interface {
+	// Take removes token from key bucket, returns time until next token when bucket is empty
+	Take(key string, rate Rate, now time.Time) (time.Duration, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+const sweepSize = 10000
+
+type InMemStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	buckets map[string]*bucket
+}
+
+func NewInMemStore() *InMemStore {
+	return &InMemStore{
+		entries: map[string]*Entry{},
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (s *InMemStore) Get(key string, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.ExpireAt) {
+		return Entry{}, nil
+	}
+	return *entry, nil
+}
+
+func (s *InMemStore) Increment(key string, window time.Duration, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) >= sweepSize {
+		s.sweep(now)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.ExpireAt) {
+		entry = &Entry{Start: now, ExpireAt: now.Add(window)}
+		s.entries[key] = entry
+	}
+
+	entry.Count++
+	entry.Last = now
+	return *entry, nil
+}
+
+func (s *InMemStore) Take(key string, rate Rate, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buckets) >= sweepSize {
+		s.sweep(now)
+	}
+
+	capacity := float64(rate.Limit)
+	refillPerSecond := capacity / rate.Window.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*refillPerSecond)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / refillPerSecond
+		return time.Duration(wait * float64(time.Second)), nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) / refillPerSecond * float64(time.Second)))
+	return 0, nil
+}
+
+func (s *InMemStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep removes expired entries so memory is not exhausted by requests with random keys
+func (s *InMemStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.ExpireAt) {
+			delete(s.entries, key)
+		}
+	}
+	// refilled bucket is the same as missing one
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
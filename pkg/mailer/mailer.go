@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+)
+
+var ErrNotConfigured = errors.New("mailer is not configured, set SMTP_HOST or MAIL_FILE")
+
+// Mailer sends plain text email messages
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// FromEnv returns SMTP mailer when SMTP_HOST is set, otherwise messages are written to MAIL_FILE.
+// Messages contain password reset tokens, so they are printed to stdout only for local development (ENV=dev)
+func FromEnv() (Mailer, error) {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		return NewSMTP(SMTPConfig{
+			Host:     host,
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}), nil
+	}
+
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriter(file), nil
+	}
+
+	if os.Getenv("ENV") == "dev" {
+		return NewWriter(os.Stdout), nil
+	}
+
+	return nil, ErrNotConfigured
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Writer prints messages instead of sending them, for local development and tests
+type Writer struct {
+	mu     sync.Mutex
+	output io.Writer
+}
+
+func NewWriter(output io.Writer) *Writer {
+	return &Writer{output: output}
+}
+
+func (m *Writer) Send(to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.output, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), to, subject, body)
+	return err
+}
@@ -0,0 +1,108 @@
+package oidc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shelex/split-specs/pkg/oidc"
+	"github.com/Shelex/split-specs/pkg/oidc/oidctest"
+)
+
+const (
+	clientID    = "split-specs"
+	redirectURL = "http://localhost/auth/oidc/callback"
+)
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+
+	server := oidctest.NewServer(clientID)
+	t.Cleanup(server.Close)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer(),
+		ClientID:    clientID,
+		RedirectURL: redirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider, server
+}
+
+// login signs in with provider and returns authorization code
+func login(t *testing.T, provider *oidc.Provider, server *oidctest.Server, verifier string, nonce string) string {
+	t.Helper()
+
+	location, err := server.Authorize(provider.AuthCodeURL("state", nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	provider, server := newProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: true})
+
+	code := login(t, provider, server, "verifier", "nonce")
+
+	claims, err := provider.Exchange(code, "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := oidc.Claims{Subject: "user-1", Email: "user@example.com", EmailVerified: true}
+	if claims != expected {
+		t.Fatalf("expected %+v, got %+v", expected, claims)
+	}
+}
+
+func TestExchangeUnverifiedEmail(t *testing.T) {
+	provider, server := newProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "user@example.com", EmailVerified: false})
+
+	code := login(t, provider, server, "verifier", "nonce")
+
+	claims, err := provider.Exchange(code, "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.EmailVerified {
+		t.Fatal("expected email to be unverified")
+	}
+}
+
+func TestExchangeRejectsCodeVerifierMismatch(t *testing.T) {
+	provider, server := newProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1"})
+
+	code := login(t, provider, server, "verifier", "nonce")
+
+	if _, err := provider.Exchange(code, "other-verifier", "nonce"); err == nil {
+		t.Fatal("expected exchange with wrong code verifier to fail")
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	provider, server := newProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1"})
+
+	code := login(t, provider, server, "verifier", "nonce")
+
+	if _, err := provider.Exchange(code, "verifier", "other-nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("expected %s, got %v", oidc.ErrInvalidIDToken, err)
+	}
+}
+
+func TestExchangeRejectsUnknownKeyID(t *testing.T) {
+	provider, server := newProvider(t)
+	server.SetIdentity(oidctest.Identity{Subject: "user-1"})
+	server.SignWithKeyID("unknown-key")
+
+	code := login(t, provider, server, "verifier", "nonce")
+
+	if _, err := provider.Exchange(code, "verifier", "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("expected %s, got %v", oidc.ErrInvalidIDToken, err)
+	}
+}
@@ -0,0 +1,153 @@
+package timing
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrEmpty = errors.New("timing file has no spec durations")
+
+// Parse reads spec file durations in seconds from JUnit XML report or from csv with file,duration lines,
+// format is detected by content
+func Parse(content string) (map[string]float64, error) {
+	trimmed := strings.TrimSpace(content)
+
+	var durations map[string]float64
+	var err error
+
+	if strings.HasPrefix(trimmed, "<") {
+		durations, err = parseJUnit(trimmed)
+	} else {
+		durations, err = parseCSV(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(durations) == 0 {
+		return nil, ErrEmpty
+	}
+	return durations, nil
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	File   string       `xml:"file,attr"`
+	Time   string       `xml:"time,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string `xml:"classname,attr"`
+	File      string `xml:"file,attr"`
+	Time      string `xml:"time,attr"`
+}
+
+// parseJUnit supports <testsuites> and single <testsuite> roots,
+// spec file is taken from file attribute of suite (cypress, mocha) or test cases (pytest, jest),
+// suite name is used when there is no file attribute
+func parseJUnit(content string) (map[string]float64, error) {
+	var root junitSuite
+	if err := xml.Unmarshal([]byte(content), &root); err != nil {
+		return nil, fmt.Errorf("failed to parse junit xml: %s", err)
+	}
+
+	// testsuites root is handled as a suite containing nested suites only
+	durations := make(map[string]float64)
+	addSuite(durations, root)
+
+	return durations, nil
+}
+
+func addSuite(durations map[string]float64, suite junitSuite) {
+	if suite.File != "" {
+		durations[suite.File] += parseSeconds(suite.Time)
+		return
+	}
+
+	for _, nested := range suite.Suites {
+		addSuite(durations, nested)
+	}
+
+	withFiles := false
+	withoutFiles := 0.0
+
+	for _, testCase := range suite.Cases {
+		if testCase.File != "" {
+			withFiles = true
+			durations[testCase.File] += parseSeconds(testCase.Time)
+			continue
+		}
+		withoutFiles += parseSeconds(testCase.Time)
+	}
+
+	// suite name is treated as spec file only for the innermost suites
+	if suite.Name == "" || len(suite.Suites) > 0 {
+		return
+	}
+
+	if !withFiles {
+		if seconds := parseSeconds(suite.Time); seconds > 0 {
+			withoutFiles = seconds
+		}
+		durations[suite.Name] += withoutFiles
+	} else if withoutFiles > 0 {
+		durations[suite.Name] += withoutFiles
+	}
+}
+
+func parseSeconds(value string) float64 {
+	seconds, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+// parseCSV reads file,duration lines, header line and lines starting with # are skipped
+func parseCSV(content string) (map[string]float64, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	durations := make(map[string]float64)
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return durations, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse csv: %s", err)
+		}
+
+		if len(record) < 2 {
+			return nil, fmt.Errorf("csv line %d: expected file,duration", line)
+		}
+
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("csv line %d: invalid duration %q", line, record[1])
+		}
+
+		if seconds < 0 {
+			return nil, fmt.Errorf("csv line %d: duration cannot be negative", line)
+		}
+
+		file := strings.TrimSpace(record[0])
+		if file == "" {
+			return nil, fmt.Errorf("csv line %d: file cannot be empty", line)
+		}
+		durations[file] += seconds
+	}
+}
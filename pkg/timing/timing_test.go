@@ -0,0 +1,94 @@
+package timing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]float64
+	}{
+		{
+			name:     "csv with header and comments",
+			content:  "# exported durations\nfile,duration\na.spec.js, 1.5\nb.spec.js,2\na.spec.js,0.5\n",
+			expected: map[string]float64{"a.spec.js": 2, "b.spec.js": 2},
+		},
+		{
+			name:     "csv without header",
+			content:  "a.spec.js,10",
+			expected: map[string]float64{"a.spec.js": 10},
+		},
+		{
+			name: "junit suites with file attribute",
+			content: `<?xml version="1.0"?>
+<testsuites>
+	<testsuite name="Root Suite" file="cypress/a.spec.js" time="12.5"><testcase time="1"/></testsuite>
+	<testsuite name="Root Suite" file="cypress/b.spec.js" time="1,000.5"></testsuite>
+</testsuites>`,
+			expected: map[string]float64{"cypress/a.spec.js": 12.5, "cypress/b.spec.js": 1000.5},
+		},
+		{
+			name: "junit test cases with file attribute",
+			content: `<testsuite name="pytest">
+	<testcase classname="a" file="tests/a.py" time="1.5"/>
+	<testcase classname="a" file="tests/a.py" time="2"/>
+	<testcase classname="b" file="tests/b.py" time="0.5"/>
+</testsuite>`,
+			expected: map[string]float64{"tests/a.py": 3.5, "tests/b.py": 0.5},
+		},
+		{
+			name: "junit suite names without file attributes",
+			content: `<testsuites name="all" time="10">
+	<testsuite name="login" time="4"><testcase time="1"/></testsuite>
+	<testsuite name="search"><testcase time="1"/><testcase time="2"/><testcase time="-1"/></testsuite>
+</testsuites>`,
+			expected: map[string]float64{"login": 4, "search": 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			durations, err := Parse(test.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(durations, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, durations)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		empty   bool
+	}{
+		{name: "empty content", content: "  \n", empty: true},
+		{name: "csv header only", content: "file,duration\n", empty: true},
+		{name: "junit without suites", content: "<testsuites></testsuites>", empty: true},
+		{name: "broken xml", content: "<testsuites><testsuite name=\"a\">"},
+		{name: "csv without duration", content: "a.spec.js"},
+		{name: "csv invalid duration", content: "a.spec.js,1\nb.spec.js,slow"},
+		{name: "csv negative duration", content: "a.spec.js,-1"},
+		{name: "csv empty file", content: "a.spec.js,1\n ,2"},
+		{name: "csv unterminated quote", content: "\"a.spec.js,1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			durations, err := Parse(test.content)
+			if err == nil {
+				t.Fatalf("expected error, got %v", durations)
+			}
+			if errors.Is(err, ErrEmpty) != test.empty {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
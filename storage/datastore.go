@@ -19,8 +19,18 @@ const (
 	sessionKind          = "sessions"
 	specKind             = "specs"
 	apiKeyKind           = "api-keys"
+	organisationKind     = "organisations"
+	orgMemberKind        = "organisation-members"
+	serviceTokenKind     = "service-tokens"
+	refreshTokenKind     = "refresh-tokens"
+	passwordResetKind    = "password-resets"
+	auditLogKind         = "audit-log"
+	baselineKind         = "baselines"
 )
 
+// datastoreBatchSize is max amount of entities in single batch operation
+const datastoreBatchSize = 500
+
 type DataStore struct {
 	Client *datastore.Client
 	ctx    context.Context
@@ -51,28 +61,37 @@ func (d DataStore) CreateProject(project entities.Project) error {
 	}
 	return nil
 }
-func (d DataStore) CreateSession(projectID string, sessionID string, specs []entities.Spec) (*entities.Session, error) {
-	sessionKey := datastore.NameKey(sessionKind, sessionID, nil)
+func (d DataStore) CreateSession(session entities.Session, specs []entities.Spec) (*entities.Session, error) {
+	sessionKey := datastore.NameKey(sessionKind, session.ID, nil)
+	session.LabelIndex = entities.LabelIndex(session.Labels)
 
-	var session *entities.Session
+	for index := range specs {
+		specs[index].ProjectID = session.ProjectID
+	}
 
-	err := d.CreateSpecs(sessionID, specs)
+	err := d.CreateSpecs(session.ID, specs)
 	if err != nil {
 		return nil, err
 	}
-	session = &entities.Session{
-		ID:        sessionID,
-		ProjectID: projectID,
-	}
-	if _, err := d.Client.Put(d.ctx, sessionKey, session); err != nil {
+	if _, err := d.Client.Put(d.ctx, sessionKey, &session); err != nil {
 		return nil, err
 	}
 
-	return session, err
+	return &session, err
+}
+
+// projectSessionsQuery selects not deleted sessions of project having all labels,
+// sessions created before soft deletion should be migrated to have deletedAt property
+func projectSessionsQuery(projectID string, labels []entities.Label) *datastore.Query {
+	query := datastore.NewQuery(sessionKind).Filter("projectId=", projectID).Filter("deletedAt=", 0)
+	for _, label := range labels {
+		query = query.Filter("labelIndex=", label.Index())
+	}
+	return query
 }
 
-func (d DataStore) GetProjectLatestSessions(projectID string, limit int) ([]*entities.Session, error) {
-	sessionQuery := datastore.NewQuery(sessionKind).Filter("projectId=", projectID).Filter("end>", 0).Order("-end").Limit(limit)
+func (d DataStore) GetProjectLatestSessions(projectID string, limit int, labels []entities.Label) ([]*entities.Session, error) {
+	sessionQuery := projectSessionsQuery(projectID, labels).Filter("end>", 0).Order("-end").Limit(limit)
 
 	var sessions []*entities.Session
 
@@ -83,6 +102,95 @@ func (d DataStore) GetProjectLatestSessions(projectID string, limit int) ([]*ent
 	return sessions, nil
 }
 
+// GetProjectSessionList returns project sessions without specs, soft deleted sessions are skipped
+func (d DataStore) GetProjectSessionList(projectID string) ([]entities.Session, error) {
+	sessions := make([]entities.Session, 0)
+
+	if _, err := d.Client.GetAll(d.ctx, projectSessionsQuery(projectID, nil), &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// MigrateSessions writes deletedAt property to sessions created before soft deletion,
+// so they are matched by queries of not deleted sessions
+func (d DataStore) MigrateSessions() (int, error) {
+	var sessions []entities.Session
+
+	keys, err := d.Client.GetAll(d.ctx, datastore.NewQuery(sessionKind), &sessions)
+	if err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(keys); start += datastoreBatchSize {
+		end := start + datastoreBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if _, err := d.Client.PutMulti(d.ctx, keys[start:end], sessions[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(keys), nil
+}
+
+// MigrateSpecs writes projectId property of session to specs created before it was introduced,
+// so they are matched by spec history query
+func (d DataStore) MigrateSpecs() (int, error) {
+	var sessions []entities.Session
+
+	if _, err := d.Client.GetAll(d.ctx, datastore.NewQuery(sessionKind), &sessions); err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	keys := make([]*datastore.Key, 0, datastoreBatchSize)
+	specs := make([]entities.Spec, 0, datastoreBatchSize)
+
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		if _, err := d.Client.PutMulti(d.ctx, keys, specs); err != nil {
+			return err
+		}
+		migrated += len(keys)
+		keys = keys[:0]
+		specs = specs[:0]
+		return nil
+	}
+
+	for _, session := range sessions {
+		sessionKey := datastore.NameKey(sessionKind, session.ID, nil)
+
+		var sessionSpecs []entities.Spec
+		specKeys, err := d.Client.GetAll(d.ctx, datastore.NewQuery(specKind).Ancestor(sessionKey), &sessionSpecs)
+		if err != nil {
+			return migrated, err
+		}
+
+		for index, spec := range sessionSpecs {
+			if spec.ProjectID != "" {
+				continue
+			}
+			spec.ProjectID = session.ProjectID
+			keys = append(keys, specKeys[index])
+			specs = append(specs, spec)
+
+			if len(keys) == datastoreBatchSize {
+				if err := flush(); err != nil {
+					return migrated, err
+				}
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return migrated, err
+	}
+	return migrated, nil
+}
+
 func (d DataStore) StartSpec(sessionID string, machineID string, specID string) error {
 	session, err := d.GetSession(sessionID)
 	if err != nil {
@@ -197,6 +305,57 @@ func (d DataStore) EndSession(sessionID string) error {
 	return nil
 }
 
+func (d DataStore) AbortSession(sessionID string, abortedBy string) error {
+	session, err := d.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.End != 0 {
+		return ErrSessionFinished
+	}
+
+	specs, err := d.GetSpecs(sessionID)
+	if err != nil {
+		return err
+	}
+
+	sessionKey := datastore.NameKey(sessionKind, sessionID, nil)
+
+	var skippedKeys []*datastore.Key
+	var skippedSpecs []entities.Spec
+
+	for _, spec := range specs {
+		if spec.Start == 0 {
+			spec.Skipped = true
+			skippedKeys = append(skippedKeys, datastore.NameKey(specKind, spec.ID, sessionKey))
+			skippedSpecs = append(skippedSpecs, spec)
+		}
+	}
+
+	session.AbortedBy = abortedBy
+	session.End = time.Now().Unix()
+
+	tx, err := d.Client.NewTransaction(d.ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(skippedKeys) > 0 {
+		if _, err := tx.PutMulti(skippedKeys, skippedSpecs); err != nil {
+			return fmt.Errorf("failed to skip specs: %s", err)
+		}
+	}
+
+	if _, err := tx.Put(sessionKey, &session); err != nil {
+		return fmt.Errorf("failed to write session abort: %s", err)
+	}
+
+	if _, err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trx abort session: %s", err)
+	}
+	return nil
+}
+
 func (d DataStore) CreateUser(user entities.User) error {
 	userKey := datastore.NameKey(userKind, user.ID, nil)
 
@@ -221,24 +380,74 @@ func (d DataStore) GetUserByEmail(email string) (*entities.User, error) {
 	return &users[0], nil
 }
 
-func (d DataStore) GetUserProjectIDByName(userID string, projectName string) (string, error) {
+func (d DataStore) GetUserByID(userID string) (*entities.User, error) {
+	userKey := datastore.NameKey(userKind, userID, nil)
+
+	var user entities.User
+	if err := d.Client.Get(d.ctx, userKey, &user); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d DataStore) GetUserByOIDCSubject(issuer string, subject string) (*entities.User, error) {
+	userQuery := datastore.NewQuery(userKind).Filter("oidcSubject=", subject)
+
+	var found []entities.User
+	if _, err := d.Client.GetAll(d.ctx, userQuery, &found); err != nil {
+		return nil, err
+	}
+
+	// subject is unique only within issuer, filtering by issuer here saves composite index
+	for _, user := range found {
+		if user.OIDCIssuer == issuer {
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (d DataStore) LinkUserToOIDCSubject(userID string, issuer string, subject string) error {
+	userKey := datastore.NameKey(userKind, userID, nil)
+
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		var user entities.User
+		if err := tx.Get(userKey, &user); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		user.OIDCIssuer = issuer
+		user.OIDCSubject = subject
+
+		_, err := tx.Put(userKey, &user)
+		return err
+	})
+	return err
+}
+
+func (d DataStore) GetUserProjectIDsByName(userID string, projectName string) ([]string, error) {
 	projectIDs, err := d.GetUserProjectIDs(userID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	var matched []string
 	for _, id := range projectIDs {
 		project, err := d.GetProjectByID(id)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		if project.Name == projectName {
-			return project.ID, nil
+			matched = append(matched, project.ID)
 		}
-
 	}
 
-	return "", ErrProjectNotFound
+	return matched, nil
 }
 
 func (d DataStore) GetProjectByID(ID string) (*entities.Project, error) {
@@ -253,7 +462,7 @@ func (d DataStore) GetProjectByID(ID string) (*entities.Project, error) {
 	return &project, nil
 }
 
-func (d DataStore) AttachProjectToUser(userID string, projectID string) error {
+func (d DataStore) AttachProjectToUser(userID string, projectID string, role string) error {
 	userKey := datastore.NameKey(userKind, userID, nil)
 
 	id, err := gonanoid.New()
@@ -265,6 +474,7 @@ func (d DataStore) AttachProjectToUser(userID string, projectID string) error {
 		ID:        id,
 		UserID:    userID,
 		ProjectID: projectID,
+		Role:      role,
 	}
 
 	userProjectKey := datastore.NameKey(userProjectKind, id, userKey)
@@ -294,6 +504,44 @@ func (d DataStore) GetProjectUsers(projectID string) ([]string, error) {
 	return userIDs, nil
 }
 
+func (d DataStore) GetProjectMembers(projectID string) ([]entities.UserProject, error) {
+	userProjectsQuery := datastore.NewQuery(userProjectKind).Filter("projectId=", projectID)
+
+	var members []entities.UserProject
+
+	if _, err := d.Client.GetAll(d.ctx, userProjectsQuery, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (d DataStore) SetProjectMemberRole(userID string, projectID string, role string) error {
+	userKey := datastore.NameKey(userKind, userID, nil)
+
+	userProjectsQuery := datastore.NewQuery(userProjectKind).Ancestor(userKey).Filter("projectId=", projectID).Limit(1)
+
+	var members []entities.UserProject
+
+	if _, err := d.Client.GetAll(d.ctx, userProjectsQuery, &members); err != nil {
+		return err
+	}
+
+	if len(members) == 0 {
+		return ErrMemberNotFound
+	}
+
+	member := members[0]
+	member.Role = role
+
+	memberKey := datastore.NameKey(userProjectKind, member.ID, userKey)
+
+	if _, err := d.Client.Put(d.ctx, memberKey, &member); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (d DataStore) UnlinkProjectFromUser(userID string, projectID string) error {
 	userKey := datastore.NameKey(userKind, userID, nil)
 
@@ -314,11 +562,33 @@ func (d DataStore) UnlinkProjectFromUser(userID string, projectID string) error
 		}
 	}
 
+	if unlinkProject.ID == "" {
+		return ErrMemberNotFound
+	}
+
 	unlinkKey := datastore.NameKey(userProjectKind, unlinkProject.ID, userKey)
 
 	return d.Client.Delete(d.ctx, unlinkKey)
 }
 
+// GetLegacyProjectMembers returns user-project links created before roles were introduced,
+// such links have no role property, so they are filtered after reading all links
+func (d DataStore) GetLegacyProjectMembers() ([]entities.UserProject, error) {
+	var links []entities.UserProject
+
+	if _, err := d.Client.GetAll(d.ctx, datastore.NewQuery(userProjectKind), &links); err != nil {
+		return nil, err
+	}
+
+	legacy := make([]entities.UserProject, 0)
+	for _, link := range links {
+		if link.Role == "" {
+			legacy = append(legacy, link)
+		}
+	}
+	return legacy, nil
+}
+
 func (d DataStore) GetUserProjectIDs(userID string) ([]string, error) {
 	userKey := datastore.NameKey(userKind, userID, nil)
 
@@ -339,6 +609,98 @@ func (d DataStore) GetUserProjectIDs(userID string) ([]string, error) {
 	return ids, nil
 }
 
+func (d DataStore) RevokeUserTokens(userID string, revokedAt int64) error {
+	userKey := datastore.NameKey(userKind, userID, nil)
+
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		var user entities.User
+		if err := tx.Get(userKey, &user); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		user.TokensRevokedAt = revokedAt
+
+		_, err := tx.Put(userKey, &user)
+		return err
+	})
+	return err
+}
+
+func (d DataStore) CreateRefreshToken(token entities.RefreshToken) error {
+	tokenKey := datastore.NameKey(refreshTokenKind, token.ID, nil)
+
+	_, err := d.Client.Put(d.ctx, tokenKey, &token)
+	return err
+}
+
+func (d DataStore) ConsumeRefreshToken(tokenID string) (entities.RefreshToken, error) {
+	tokenKey := datastore.NameKey(refreshTokenKind, tokenID, nil)
+
+	var token entities.RefreshToken
+	// token is deleted in the same transaction, so it could be exchanged only once
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		if err := tx.Get(tokenKey, &token); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrRefreshTokenNotFound
+			}
+			return err
+		}
+		return tx.Delete(tokenKey)
+	})
+	if err != nil {
+		return entities.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (d DataStore) DeleteUserRefreshTokens(userID string) error {
+	tokenQuery := datastore.NewQuery(refreshTokenKind).Filter("userId=", userID).KeysOnly()
+
+	keys, err := d.Client.GetAll(d.ctx, tokenQuery, nil)
+	if err != nil {
+		return err
+	}
+	return d.Client.DeleteMulti(d.ctx, keys)
+}
+
+func (d DataStore) CreatePasswordReset(reset entities.PasswordReset) error {
+	resetKey := datastore.NameKey(passwordResetKind, reset.ID, nil)
+
+	_, err := d.Client.Put(d.ctx, resetKey, &reset)
+	return err
+}
+
+func (d DataStore) ConsumePasswordReset(resetID string) (entities.PasswordReset, error) {
+	resetKey := datastore.NameKey(passwordResetKind, resetID, nil)
+
+	var reset entities.PasswordReset
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		if err := tx.Get(resetKey, &reset); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return ErrPasswordResetNotFound
+			}
+			return err
+		}
+		return tx.Delete(resetKey)
+	})
+	if err != nil {
+		return entities.PasswordReset{}, err
+	}
+	return reset, nil
+}
+
+func (d DataStore) DeleteUserPasswordResets(userID string) error {
+	resetQuery := datastore.NewQuery(passwordResetKind).Filter("userId=", userID).KeysOnly()
+
+	keys, err := d.Client.GetAll(d.ctx, resetQuery, nil)
+	if err != nil {
+		return err
+	}
+	return d.Client.DeleteMulti(d.ctx, keys)
+}
+
 func (d DataStore) UpdatePassword(userID string, newPassword string) error {
 	userKey := datastore.NameKey(userKind, userID, nil)
 
@@ -401,28 +763,139 @@ func (d DataStore) GetSpecs(sessionID string) ([]entities.Spec, error) {
 	return specs, nil
 }
 
-func (d DataStore) DeleteSession(email string, sessionID string) error {
-	sessionKey := datastore.NameKey(sessionKind, sessionID, nil)
+// GetProjectFinishedSpecs returns specs of project finished since timestamp in a single query
+func (d DataStore) GetProjectFinishedSpecs(projectID string, since int64) ([]entities.Spec, error) {
+	query := datastore.NewQuery(specKind).Filter("projectId=", projectID).Filter("end>=", since)
 
-	session, err := d.GetSession(sessionID)
+	specs := make([]entities.Spec, 0)
+
+	if _, err := d.Client.GetAll(d.ctx, query, &specs); err != nil {
+		return nil, err
+	}
+
+	finished := make([]entities.Spec, 0, len(specs))
+	for _, spec := range specs {
+		if spec.End > 0 {
+			finished = append(finished, spec)
+		}
+	}
+	return finished, nil
+}
+
+func (d DataStore) GetSpecHistory(projectID string, filePath string, pagination *entities.Pagination) ([]entities.Spec, int, error) {
+	specQuery := datastore.NewQuery(specKind).Filter("projectId=", projectID).Filter("filePath=", filePath).Filter("start>", 0).Order("-start")
+
+	deletedSessions, err := d.getDeletedSessionIDs(projectID)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	user, err := d.GetUserByEmail(email)
+	// specs of soft deleted sessions are filtered out in code, so pagination is applied after it
+	if len(deletedSessions) > 0 {
+		var specs []entities.Spec
+		if _, err := d.Client.GetAll(d.ctx, specQuery, &specs); err != nil {
+			return nil, 0, err
+		}
+
+		history := make([]entities.Spec, 0, len(specs))
+		for _, spec := range specs {
+			if !deletedSessions[spec.SessionID] {
+				history = append(history, spec)
+			}
+		}
+		page, total := paginateSpecs(history, pagination)
+		return page, total, nil
+	}
+
+	total, err := d.Client.Count(d.ctx, specQuery)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+
+	if pagination != nil {
+		specQuery = specQuery.Offset(pagination.Offset).Limit(pagination.Limit)
 	}
 
-	users, err := d.GetProjectUsers(session.ProjectID)
+	specs := make([]entities.Spec, 0)
+
+	if _, err := d.Client.GetAll(d.ctx, specQuery, &specs); err != nil {
+		return nil, 0, err
+	}
+
+	return specs, total, nil
+}
+
+// getDeletedSessionIDs returns set of soft deleted session ids of the project
+func (d DataStore) getDeletedSessionIDs(projectID string) (map[string]bool, error) {
+	query := datastore.NewQuery(sessionKind).Filter("projectId=", projectID).Filter("deletedAt>", 0).KeysOnly()
+
+	keys, err := d.Client.GetAll(d.ctx, query, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hasAccess, _ := contains(users, user.ID)
+	deleted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		deleted[key.Name] = true
+	}
+	return deleted, nil
+}
 
-	if !hasAccess {
-		return ErrSessionNotFound
+func paginateSpecs(specs []entities.Spec, pagination *entities.Pagination) ([]entities.Spec, int) {
+	total := len(specs)
+
+	if pagination == nil {
+		return specs, total
+	}
+	if pagination.Offset >= total {
+		return []entities.Spec{}, total
+	}
+	specs = specs[pagination.Offset:]
+	if pagination.Limit < len(specs) {
+		specs = specs[:pagination.Limit]
+	}
+	return specs, total
+}
+
+func (d DataStore) CreateAuditEntry(entry entities.AuditEntry) error {
+	entryKey := datastore.NameKey(auditLogKind, entry.ID, nil)
+
+	_, err := d.Client.Put(d.ctx, entryKey, &entry)
+	return err
+}
+
+func (d DataStore) GetProjectAuditLog(projectID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	return d.getAuditLog(datastore.NewQuery(auditLogKind).Filter("projectId=", projectID).Order("-timestamp"), pagination)
+}
+
+func (d DataStore) GetUserAuditLog(userID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	return d.getAuditLog(datastore.NewQuery(auditLogKind).Filter("actorId=", userID).Order("-timestamp"), pagination)
+}
+
+func (d DataStore) getAuditLog(query *datastore.Query, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	total, err := d.Client.Count(d.ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if pagination != nil {
+		query = query.Offset(pagination.Offset).Limit(pagination.Limit)
+	}
+
+	entries := make([]entities.AuditEntry, 0)
+
+	if _, err := d.Client.GetAll(d.ctx, query, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+func (d DataStore) DeleteSession(sessionID string) error {
+	sessionKey := datastore.NameKey(sessionKind, sessionID, nil)
+
+	if _, err := d.GetSession(sessionID); err != nil {
+		return err
 	}
 
 	specs, err := d.GetSpecs(sessionID)
@@ -455,43 +928,221 @@ func (d DataStore) DeleteSession(email string, sessionID string) error {
 	return nil
 }
 
-func (d DataStore) DeleteProject(email string, projectID string) error {
-	user, err := d.GetUserByEmail(email)
+func (d DataStore) SetSessionDeleted(sessionID string, deletedAt int64) error {
+	session, err := d.GetSession(sessionID)
 	if err != nil {
 		return err
 	}
 
-	projectUsers, err := d.GetProjectUsers(projectID)
+	session.DeletedAt = deletedAt
+
+	sessionKey := datastore.NameKey(sessionKind, sessionID, nil)
+
+	if _, err := d.Client.Put(d.ctx, sessionKey, &session); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) GetDeletedSessions(before int64) ([]entities.Session, error) {
+	query := datastore.NewQuery(sessionKind).Filter("deletedAt>", 0).Filter("deletedAt<", before)
+
+	var sessions []entities.Session
+
+	if _, err := d.Client.GetAll(d.ctx, query, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (d DataStore) SetProjectDeleted(projectID string, deletedAt int64) error {
+	project, err := d.GetProjectByID(projectID)
 	if err != nil {
 		return err
 	}
 
-	hasAccess, _ := contains(projectUsers, user.ID)
+	project.DeletedAt = deletedAt
+
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	if _, err := d.Client.Put(d.ctx, projectKey, project); err != nil {
+		return err
+	}
+	return nil
+}
 
-	if !hasAccess {
-		return ErrProjectNotFound
+func (d DataStore) SetProjectRetention(projectID string, policy entities.RetentionPolicy) error {
+	project, err := d.GetProjectByID(projectID)
+	if err != nil {
+		return err
 	}
 
-	if len(projectUsers) == 1 {
-		sessions, _, err := d.GetProjectSessions(projectID, nil)
-		if err != nil {
-			return err
+	project.RetentionKeepLast = policy.KeepLast
+	project.RetentionMaxAgeDays = policy.MaxAgeDays
+
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	if _, err := d.Client.Put(d.ctx, projectKey, project); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetProjectsWithRetention returns projects having any retention limit,
+// datastore does not support OR filters so both limits are queried separately
+func (d DataStore) GetProjectsWithRetention() ([]entities.Project, error) {
+	seen := make(map[string]bool)
+	var projects []entities.Project
+
+	for _, property := range []string{"retentionKeepLast>", "retentionMaxAgeDays>"} {
+		var found []entities.Project
+
+		if _, err := d.Client.GetAll(d.ctx, datastore.NewQuery(projectKind).Filter(property, 0), &found); err != nil {
+			return nil, err
 		}
 
-		for _, session := range sessions {
-			if err := d.DeleteSession(email, session.ID); err != nil {
-				return err
+		for _, project := range found {
+			if seen[project.ID] {
+				continue
 			}
+			seen[project.ID] = true
+			projects = append(projects, project)
 		}
+	}
+	return projects, nil
+}
 
-		projectKey := datastore.NameKey(projectKind, projectID, nil)
+func (d DataStore) SetProjectEstimationSource(projectID string, sourceID string, setBy string) error {
+	project, err := d.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
 
-		if err := d.Client.Delete(d.ctx, projectKey); err != nil {
+	project.EstimationSourceID = sourceID
+	project.EstimationSourceSetBy = setBy
+
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	if _, err := d.Client.Put(d.ctx, projectKey, project); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddProjectEstimationKeys adds label keys missing in estimation keys of project
+func (d DataStore) AddProjectEstimationKeys(projectID string, keys []string) error {
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		var project entities.Project
+		if err := tx.Get(projectKey, &project); err != nil {
 			return err
 		}
+
+		project.EstimationKeys = mergeKeys(project.EstimationKeys, keys)
+
+		_, err := tx.Put(projectKey, &project)
+		return err
+	})
+	return err
+}
+
+// SetProjectBaseline replaces baseline of project, baselines are stored as children of project key
+func (d DataStore) SetProjectBaseline(projectID string, baselines []entities.SpecBaseline) error {
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	existingKeys, err := d.Client.GetAll(d.ctx, datastore.NewQuery(baselineKind).Ancestor(projectKey).KeysOnly(), nil)
+	if err != nil {
+		return err
 	}
 
-	return d.UnlinkProjectFromUser(user.ID, projectID)
+	// datastore limits amount of entities in a single batch operation
+	for start := 0; start < len(existingKeys); start += datastoreBatchSize {
+		end := start + datastoreBatchSize
+		if end > len(existingKeys) {
+			end = len(existingKeys)
+		}
+		if err := d.Client.DeleteMulti(d.ctx, existingKeys[start:end]); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]*datastore.Key, len(baselines))
+	for index := range baselines {
+		baselines[index].ProjectID = projectID
+		keys[index] = datastore.NameKey(baselineKind, baselines[index].FilePath, projectKey)
+	}
+
+	for start := 0; start < len(keys); start += datastoreBatchSize {
+		end := start + datastoreBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if _, err := d.Client.PutMulti(d.ctx, keys[start:end], baselines[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d DataStore) GetProjectBaseline(projectID string) ([]entities.SpecBaseline, error) {
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	var baselines []entities.SpecBaseline
+
+	if _, err := d.Client.GetAll(d.ctx, datastore.NewQuery(baselineKind).Ancestor(projectKey), &baselines); err != nil {
+		return nil, err
+	}
+	return baselines, nil
+}
+
+func (d DataStore) GetDeletedProjects(before int64) ([]entities.Project, error) {
+	query := datastore.NewQuery(projectKind).Filter("deletedAt>", 0).Filter("deletedAt<", before)
+
+	var projects []entities.Project
+
+	if _, err := d.Client.GetAll(d.ctx, query, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (d DataStore) DeleteProject(projectID string) error {
+	projectUsers, err := d.GetProjectUsers(projectID)
+	if err != nil {
+		return err
+	}
+
+	// soft deleted sessions are removed as well
+	sessionQuery := datastore.NewQuery(sessionKind).Filter("projectId=", projectID).KeysOnly()
+
+	sessionKeys, err := d.Client.GetAll(d.ctx, sessionQuery, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionKey := range sessionKeys {
+		if err := d.DeleteSession(sessionKey.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := d.SetProjectBaseline(projectID, nil); err != nil {
+		return err
+	}
+
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	if err := d.Client.Delete(d.ctx, projectKey); err != nil {
+		return err
+	}
+
+	for _, projectUser := range projectUsers {
+		if err := d.UnlinkProjectFromUser(projectUser, projectID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d DataStore) GetSessionWithSpecs(sessionID string) (entities.SessionWithSpecs, error) {
@@ -508,17 +1159,22 @@ func (d DataStore) GetSessionWithSpecs(sessionID string) (entities.SessionWithSp
 	}
 
 	return entities.SessionWithSpecs{
-		ID:        session.ID,
-		ProjectID: session.ProjectID,
-		Start:     session.Start,
-		End:       session.End,
-		Specs:     specs,
+		ID:                 session.ID,
+		ProjectID:          session.ProjectID,
+		Start:              session.Start,
+		End:                session.End,
+		AbortAfterFailures: session.AbortAfterFailures,
+		AbortedBy:          session.AbortedBy,
+		DeletedAt:          session.DeletedAt,
+		Labels:             session.Labels,
+		LabelIndex:         session.LabelIndex,
+		Specs:              specs,
 	}, nil
 
 }
 
-func (d DataStore) GetProjectSessions(projectID string, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
-	sessionQuery := datastore.NewQuery(sessionKind).Filter("projectId=", projectID).Order("-end")
+func (d DataStore) GetProjectSessions(projectID string, labels []entities.Label, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
+	sessionQuery := projectSessionsQuery(projectID, labels).Order("-end")
 
 	total, err := d.Client.Count(d.ctx, sessionQuery)
 	if err != nil {
@@ -529,7 +1185,7 @@ func (d DataStore) GetProjectSessions(projectID string, pagination *entities.Pag
 		sessionQuery = sessionQuery.Offset(pagination.Offset).Limit(pagination.Limit)
 	}
 
-	var sessions []entities.SessionWithSpecs
+	sessions := make([]entities.SessionWithSpecs, 0)
 
 	if _, err := d.Client.GetAll(d.ctx, sessionQuery, &sessions); err != nil {
 		return nil, 0, err
@@ -549,6 +1205,208 @@ func (d DataStore) GetProjectSessions(projectID string, pagination *entities.Pag
 	return sessions, total, nil
 }
 
+func (d DataStore) CreateOrganisation(organisation entities.Organisation) error {
+	organisationKey := datastore.NameKey(organisationKind, organisation.ID, nil)
+	if _, err := d.Client.Put(d.ctx, organisationKey, &organisation); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) GetOrganisationByID(ID string) (*entities.Organisation, error) {
+	organisationKey := datastore.NameKey(organisationKind, ID, nil)
+
+	var organisation entities.Organisation
+	if err := d.Client.Get(d.ctx, organisationKey, &organisation); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, ErrOrganisationNotFound
+		}
+		return nil, err
+	}
+	return &organisation, nil
+}
+
+func (d DataStore) GetOrganisationByName(name string) (*entities.Organisation, error) {
+	query := datastore.NewQuery(organisationKind).Filter("name=", name).Limit(1)
+
+	var organisations []entities.Organisation
+
+	if _, err := d.Client.GetAll(d.ctx, query, &organisations); err != nil {
+		return nil, err
+	}
+	if len(organisations) == 0 {
+		return nil, ErrOrganisationNotFound
+	}
+	return &organisations[0], nil
+}
+
+func (d DataStore) GetOrganisationMembers(organisationID string) ([]entities.OrganisationMember, error) {
+	organisationKey := datastore.NameKey(organisationKind, organisationID, nil)
+	query := datastore.NewQuery(orgMemberKind).Ancestor(organisationKey)
+
+	var members []entities.OrganisationMember
+
+	if _, err := d.Client.GetAll(d.ctx, query, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (d DataStore) GetUserOrganisations(userID string) ([]entities.OrganisationMember, error) {
+	query := datastore.NewQuery(orgMemberKind).Filter("userId=", userID)
+
+	var memberships []entities.OrganisationMember
+
+	if _, err := d.Client.GetAll(d.ctx, query, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+func (d DataStore) AttachUserToOrganisation(userID string, organisationID string, role string) error {
+	organisationKey := datastore.NameKey(organisationKind, organisationID, nil)
+
+	members, err := d.GetOrganisationMembers(organisationID)
+	if err != nil {
+		return err
+	}
+
+	member := entities.OrganisationMember{
+		OrganisationID: organisationID,
+		UserID:         userID,
+	}
+
+	for _, existing := range members {
+		if existing.UserID == userID {
+			member = existing
+			break
+		}
+	}
+
+	if member.ID == "" {
+		id, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		member.ID = id
+	}
+
+	member.Role = role
+
+	memberKey := datastore.NameKey(orgMemberKind, member.ID, organisationKey)
+
+	if _, err := d.Client.Put(d.ctx, memberKey, &member); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) UnlinkUserFromOrganisation(userID string, organisationID string) error {
+	organisationKey := datastore.NameKey(organisationKind, organisationID, nil)
+
+	members, err := d.GetOrganisationMembers(organisationID)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.UserID == userID {
+			return d.Client.Delete(d.ctx, datastore.NameKey(orgMemberKind, member.ID, organisationKey))
+		}
+	}
+	return ErrMemberNotFound
+}
+
+func (d DataStore) GetOrganisationProjects(organisationID string) ([]entities.Project, error) {
+	query := datastore.NewQuery(projectKind).Filter("organisationId=", organisationID)
+
+	var projects []entities.Project
+
+	if _, err := d.Client.GetAll(d.ctx, query, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (d DataStore) SetProjectOrganisation(projectID string, organisationID string) error {
+	project, err := d.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+
+	project.OrganisationID = organisationID
+
+	projectKey := datastore.NameKey(projectKind, projectID, nil)
+
+	if _, err := d.Client.Put(d.ctx, projectKey, project); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) CreateServiceToken(token entities.ServiceToken) error {
+	tokenKey := datastore.NameKey(serviceTokenKind, token.ID, nil)
+	if _, err := d.Client.Put(d.ctx, tokenKey, &token); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) GetServiceToken(tokenID string) (entities.ServiceToken, error) {
+	tokenKey := datastore.NameKey(serviceTokenKind, tokenID, nil)
+
+	var token entities.ServiceToken
+	if err := d.Client.Get(d.ctx, tokenKey, &token); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return entities.ServiceToken{}, ErrServiceTokenNotFound
+		}
+		return entities.ServiceToken{}, err
+	}
+	return token, nil
+}
+
+func (d DataStore) GetProjectServiceTokens(projectID string) ([]entities.ServiceToken, error) {
+	query := datastore.NewQuery(serviceTokenKind).Filter("projectId=", projectID)
+
+	var tokens []entities.ServiceToken
+
+	if _, err := d.Client.GetAll(d.ctx, query, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (d DataStore) DeleteServiceToken(projectID string, tokenID string) error {
+	token, err := d.GetServiceToken(tokenID)
+	if err != nil {
+		return err
+	}
+
+	if token.ProjectID != projectID {
+		return ErrServiceTokenNotFound
+	}
+
+	return d.Client.Delete(d.ctx, datastore.NameKey(serviceTokenKind, tokenID, nil))
+}
+
+func (d DataStore) TouchServiceToken(tokenID string, usedAt int64) error {
+	tokenKey := datastore.NameKey(serviceTokenKind, tokenID, nil)
+
+	// transaction keeps token deleted when it is deleted before usage is flushed
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		var token entities.ServiceToken
+		if err := tx.Get(tokenKey, &token); err != nil {
+			return err
+		}
+
+		token.LastUsedAt = usedAt
+
+		_, err := tx.Put(tokenKey, &token)
+		return err
+	})
+	return err
+}
+
 func (d DataStore) CreateApiKey(userID string, key entities.ApiKey) error {
 	userKey := datastore.NameKey(userKind, userID, nil)
 	apiNameKey := datastore.NameKey(apiKeyKind, key.ID, userKey)
@@ -608,3 +1466,23 @@ func (d DataStore) GetApiKey(userID string, keyID string) (entities.ApiKey, erro
 
 	return apiKeys[0], nil
 }
+
+func (d DataStore) TrackApiKeyUsage(userID string, keyID string, ip string, usedAt int64, count int64) error {
+	userKey := datastore.NameKey(userKind, userID, nil)
+	apiNameKey := datastore.NameKey(apiKeyKind, keyID, userKey)
+
+	_, err := d.Client.RunInTransaction(d.ctx, func(tx *datastore.Transaction) error {
+		var key entities.ApiKey
+		if err := tx.Get(apiNameKey, &key); err != nil {
+			return err
+		}
+
+		key.LastUsedAt = usedAt
+		key.LastIP = ip
+		key.RequestCount += count
+
+		_, err := tx.Put(apiNameKey, &key)
+		return err
+	})
+	return err
+}
@@ -2,44 +2,147 @@ package storage
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/Shelex/split-specs/entities"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
+// InMem keeps data in maps guarded by mutex, as background jobs run concurrently with requests
 type InMem struct {
-	sessions     map[string]*entities.Session
-	projects     map[string]*entities.Project
-	users        map[string]*entities.User
-	specs        map[string]*entities.Spec
-	userProjects map[string]*entities.UserProject
-	apiKeys      map[string]*entities.ApiKey
+	mu sync.RWMutex
+
+	sessions            map[string]*entities.Session
+	projects            map[string]*entities.Project
+	users               map[string]*entities.User
+	specs               map[string]*entities.Spec
+	userProjects        map[string]*entities.UserProject
+	apiKeys             map[string]*entities.ApiKey
+	organisations       map[string]*entities.Organisation
+	organisationMembers map[string]*entities.OrganisationMember
+	serviceTokens       map[string]*entities.ServiceToken
+	refreshTokens       map[string]*entities.RefreshToken
+	passwordResets      map[string]*entities.PasswordReset
+	auditLog            []entities.AuditEntry
+	baselines           map[string][]entities.SpecBaseline
 }
 
 func NewInMemStorage() (Storage, error) {
 	DB = &InMem{
-		sessions:     map[string]*entities.Session{},
-		projects:     map[string]*entities.Project{},
-		users:        map[string]*entities.User{},
-		specs:        map[string]*entities.Spec{},
-		userProjects: map[string]*entities.UserProject{},
-		apiKeys:      map[string]*entities.ApiKey{},
+		sessions:            map[string]*entities.Session{},
+		projects:            map[string]*entities.Project{},
+		users:               map[string]*entities.User{},
+		specs:               map[string]*entities.Spec{},
+		userProjects:        map[string]*entities.UserProject{},
+		apiKeys:             map[string]*entities.ApiKey{},
+		organisations:       map[string]*entities.Organisation{},
+		organisationMembers: map[string]*entities.OrganisationMember{},
+		serviceTokens:       map[string]*entities.ServiceToken{},
+		refreshTokens:       map[string]*entities.RefreshToken{},
+		passwordResets:      map[string]*entities.PasswordReset{},
+		baselines:           map[string][]entities.SpecBaseline{},
 	}
 	return DB, nil
 }
 
 func (i *InMem) CreateUser(userInput entities.User) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	i.users[userInput.ID] = &userInput
 	return nil
 }
 
 func (i *InMem) UpdatePassword(userID string, newPassword string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	i.users[userID].Password = newPassword
 	return nil
 }
 
+func (i *InMem) RevokeUserTokens(userID string, revokedAt int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	user, ok := i.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.TokensRevokedAt = revokedAt
+	return nil
+}
+
+func (i *InMem) CreateRefreshToken(token entities.RefreshToken) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.refreshTokens[token.ID] = &token
+	return nil
+}
+
+func (i *InMem) ConsumeRefreshToken(tokenID string) (entities.RefreshToken, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	token, ok := i.refreshTokens[tokenID]
+	if !ok {
+		return entities.RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	delete(i.refreshTokens, tokenID)
+	return *token, nil
+}
+
+func (i *InMem) DeleteUserRefreshTokens(userID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for id, token := range i.refreshTokens {
+		if token.UserID == userID {
+			delete(i.refreshTokens, id)
+		}
+	}
+	return nil
+}
+
+func (i *InMem) CreatePasswordReset(reset entities.PasswordReset) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.passwordResets[reset.ID] = &reset
+	return nil
+}
+
+func (i *InMem) ConsumePasswordReset(resetID string) (entities.PasswordReset, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	reset, ok := i.passwordResets[resetID]
+	if !ok {
+		return entities.PasswordReset{}, ErrPasswordResetNotFound
+	}
+	delete(i.passwordResets, resetID)
+	return *reset, nil
+}
+
+func (i *InMem) DeleteUserPasswordResets(userID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for id, reset := range i.passwordResets {
+		if reset.UserID == userID {
+			delete(i.passwordResets, id)
+		}
+	}
+	return nil
+}
+
 func (i *InMem) GetUserByEmail(email string) (*entities.User, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	for _, user := range i.users {
 		if user.Email == email {
 			return user, nil
@@ -48,7 +151,49 @@ func (i *InMem) GetUserByEmail(email string) (*entities.User, error) {
 	return nil, fmt.Errorf("user not found")
 }
 
+func (i *InMem) GetUserByID(userID string) (*entities.User, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	user, ok := i.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (i *InMem) GetUserByOIDCSubject(issuer string, subject string) (*entities.User, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, user := range i.users {
+		if user.OIDCIssuer == issuer && user.OIDCSubject == subject {
+			return user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (i *InMem) LinkUserToOIDCSubject(userID string, issuer string, subject string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	user, ok := i.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.OIDCIssuer = issuer
+	user.OIDCSubject = subject
+	return nil
+}
+
 func (i *InMem) GetUserProjectIDs(userID string) ([]string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getUserProjectIDs(userID)
+}
+
+func (i *InMem) getUserProjectIDs(userID string) ([]string, error) {
 	var projectIds []string
 	for _, userProject := range i.userProjects {
 		if userProject.UserID == userID {
@@ -58,26 +203,36 @@ func (i *InMem) GetUserProjectIDs(userID string) ([]string, error) {
 	return projectIds, nil
 }
 
-func (i *InMem) GetUserProjectIDByName(userID string, projectName string) (string, error) {
-	projectIds, err := i.GetUserProjectIDs(userID)
+func (i *InMem) GetUserProjectIDsByName(userID string, projectName string) ([]string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	projectIds, err := i.getUserProjectIDs(userID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	var matched []string
 	for _, id := range projectIds {
-		project, err := i.GetProjectByID(id)
+		project, err := i.getProjectByID(id)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		if project.Name == projectName {
-			return project.ID, nil
+			matched = append(matched, project.ID)
 		}
 	}
 
-	return "", ErrProjectNotFound
+	return matched, nil
 }
 
 func (i *InMem) GetProjectByID(ID string) (*entities.Project, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getProjectByID(ID)
+}
+
+func (i *InMem) getProjectByID(ID string) (*entities.Project, error) {
 	project, ok := i.projects[ID]
 	if !ok {
 		return nil, ErrProjectNotFound
@@ -86,12 +241,18 @@ func (i *InMem) GetProjectByID(ID string) (*entities.Project, error) {
 }
 
 func (i *InMem) CreateProject(project entities.Project) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	i.projects[project.ID] = &project
 	return nil
 }
 
-func (i *InMem) AttachProjectToUser(userID string, projectID string) error {
-	users, err := i.GetProjectUsers(projectID)
+func (i *InMem) AttachProjectToUser(userID string, projectID string, role string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	users, err := i.getProjectUsers(projectID)
 	if err != nil {
 		return err
 	}
@@ -110,12 +271,19 @@ func (i *InMem) AttachProjectToUser(userID string, projectID string) error {
 		ID:        id,
 		UserID:    userID,
 		ProjectID: projectID,
+		Role:      role,
 	}
 	i.userProjects[id] = &userProject
 	return nil
 }
 
 func (i *InMem) GetProjectUsers(projectID string) ([]string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getProjectUsers(projectID)
+}
+
+func (i *InMem) getProjectUsers(projectID string) ([]string, error) {
 	var userIDs []string
 	for _, userProject := range i.userProjects {
 		if userProject.ProjectID == projectID {
@@ -125,33 +293,96 @@ func (i *InMem) GetProjectUsers(projectID string) ([]string, error) {
 	return userIDs, nil
 }
 
-func (i *InMem) CreateSession(projectID string, sessionID string, specs []entities.Spec) (*entities.Session, error) {
-	if _, ok := i.sessions[sessionID]; ok {
-		return nil, fmt.Errorf("[repository]: session id already in use for project %s", projectID)
+func (i *InMem) GetProjectMembers(projectID string) ([]entities.UserProject, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var members []entities.UserProject
+	for _, userProject := range i.userProjects {
+		if userProject.ProjectID == projectID {
+			members = append(members, *userProject)
+		}
 	}
+	return members, nil
+}
 
-	err := i.CreateSpecs(sessionID, specs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create specs")
+func (i *InMem) SetProjectMemberRole(userID string, projectID string, role string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, userProject := range i.userProjects {
+		if userProject.UserID == userID && userProject.ProjectID == projectID {
+			userProject.Role = role
+			return nil
+		}
 	}
+	return ErrMemberNotFound
+}
 
-	session := &entities.Session{
-		ID:        sessionID,
-		ProjectID: projectID,
+func (i *InMem) UnlinkProjectFromUser(userID string, projectID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for id, userProject := range i.userProjects {
+		if userProject.UserID == userID && userProject.ProjectID == projectID {
+			delete(i.userProjects, id)
+			return nil
+		}
 	}
+	return ErrMemberNotFound
+}
 
-	i.sessions[sessionID] = session
-	return session, nil
+func (i *InMem) GetLegacyProjectMembers() ([]entities.UserProject, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	legacy := make([]entities.UserProject, 0)
+	for _, userProject := range i.userProjects {
+		if userProject.Role == "" {
+			legacy = append(legacy, *userProject)
+		}
+	}
+	return legacy, nil
 }
 
-func (i *InMem) GetProjectLatestSessions(projectID string, limit int) ([]*entities.Session, error) {
+func (i *InMem) CreateSession(session entities.Session, specs []entities.Spec) (*entities.Session, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.sessions[session.ID]; ok {
+		return nil, fmt.Errorf("[repository]: session id already in use for project %s", session.ProjectID)
+	}
+
+	session.LabelIndex = entities.LabelIndex(session.Labels)
+
+	for index := range specs {
+		specs[index].ProjectID = session.ProjectID
+	}
+
+	err := i.createSpecs(session.ID, specs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create specs")
+	}
+
+	i.sessions[session.ID] = &session
+	return &session, nil
+}
+
+func (i *InMem) GetProjectLatestSessions(projectID string, limit int, labels []entities.Label) ([]*entities.Session, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	var sessions []*entities.Session
 
-	projectSessions, _, err := i.GetProjectSessions(projectID, nil)
+	projectSessions, _, err := i.getProjectSessions(projectID, labels, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	sort.Slice(projectSessions, func(a, b int) bool {
+		return projectSessions[a].End > projectSessions[b].End
+	})
+
 	for _, projectSession := range projectSessions {
 		session, ok := i.sessions[projectSession.ID]
 		if !ok {
@@ -168,7 +399,26 @@ func (i *InMem) GetProjectLatestSessions(projectID string, limit int) ([]*entiti
 	return sessions, nil
 }
 
+func (i *InMem) GetProjectSessionList(projectID string) ([]entities.Session, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var sessions []entities.Session
+	for _, session := range i.sessions {
+		if session.ProjectID == projectID && session.DeletedAt == 0 {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
 func (i *InMem) GetSession(sessionID string) (entities.Session, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getSession(sessionID)
+}
+
+func (i *InMem) getSession(sessionID string) (entities.Session, error) {
 	var empty entities.Session
 	session, ok := i.sessions[sessionID]
 	if !ok {
@@ -179,12 +429,15 @@ func (i *InMem) GetSession(sessionID string) (entities.Session, error) {
 }
 
 func (i *InMem) StartSpec(sessionID string, machineID string, specID string) error {
-	session, err := i.GetSession(sessionID)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	session, err := i.getSession(sessionID)
 	if err != nil {
 		return err
 	}
 
-	spec, err := i.GetSpec(specID)
+	spec, err := i.getSpec(specID)
 	if err != nil {
 		return err
 	}
@@ -199,7 +452,10 @@ func (i *InMem) StartSpec(sessionID string, machineID string, specID string) err
 }
 
 func (i *InMem) EndSpec(sessionID string, machineID string, isPassed bool) error {
-	specs, err := i.GetSpecs(sessionID)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	specs, err := i.getSpecs(sessionID)
 	if err != nil {
 		return err
 	}
@@ -216,21 +472,61 @@ func (i *InMem) EndSpec(sessionID string, machineID string, isPassed bool) error
 }
 
 func (i *InMem) EndSession(sessionID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	i.sessions[sessionID].End = time.Now().Unix()
 	return nil
 }
 
+func (i *InMem) AbortSession(sessionID string, abortedBy string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	session, ok := i.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	if session.End != 0 {
+		return ErrSessionFinished
+	}
+
+	for _, spec := range i.specs {
+		if spec.SessionID == sessionID && spec.Start == 0 {
+			spec.Skipped = true
+		}
+	}
+
+	session.AbortedBy = abortedBy
+	session.End = time.Now().Unix()
+	return nil
+}
+
 func (i *InMem) CreateSpecs(sessionID string, specs []entities.Spec) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.createSpecs(sessionID, specs)
+}
+
+func (i *InMem) createSpecs(sessionID string, specs []entities.Spec) error {
 	for _, spec := range specs {
 		id, _ := gonanoid.New()
-		spec.ID = id
-		spec.SessionID = sessionID
-		i.specs[spec.ID] = &spec
+		created := spec
+		created.ID = id
+		created.SessionID = sessionID
+		i.specs[created.ID] = &created
 	}
 	return nil
 }
 
 func (i *InMem) GetSpec(specID string) (entities.Spec, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getSpec(specID)
+}
+
+func (i *InMem) getSpec(specID string) (entities.Spec, error) {
 	spec, ok := i.specs[specID]
 
 	if !ok {
@@ -241,6 +537,12 @@ func (i *InMem) GetSpec(specID string) (entities.Spec, error) {
 }
 
 func (i *InMem) GetSpecs(sessionID string) ([]entities.Spec, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getSpecs(sessionID)
+}
+
+func (i *InMem) getSpecs(sessionID string) ([]entities.Spec, error) {
 	var specs []entities.Spec
 
 	for _, spec := range i.specs {
@@ -252,41 +554,144 @@ func (i *InMem) GetSpecs(sessionID string) ([]entities.Spec, error) {
 	return specs, nil
 }
 
-func (i *InMem) DeleteProject(email string, projectID string) error {
-	user, err := i.GetUserByEmail(email)
-	if err != nil {
-		return err
+func (i *InMem) GetProjectFinishedSpecs(projectID string, since int64) ([]entities.Spec, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var specs []entities.Spec
+
+	for _, spec := range i.specs {
+		if spec.ProjectID == projectID && spec.End > 0 && spec.End >= since {
+			specs = append(specs, *spec)
+		}
 	}
 
-	users, err := i.GetProjectUsers(projectID)
-	if err != nil {
-		return err
+	return specs, nil
+}
+
+func (i *InMem) CreateAuditEntry(entry entities.AuditEntry) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.auditLog = append(i.auditLog, entry)
+	return nil
+}
+
+func (i *InMem) GetProjectAuditLog(projectID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.filterAuditLog(func(entry entities.AuditEntry) bool {
+		return entry.ProjectID == projectID
+	}, pagination)
+}
+
+func (i *InMem) GetUserAuditLog(userID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.filterAuditLog(func(entry entities.AuditEntry) bool {
+		return entry.ActorID == userID
+	}, pagination)
+}
+
+func (i *InMem) filterAuditLog(matches func(entry entities.AuditEntry) bool, pagination *entities.Pagination) ([]entities.AuditEntry, int, error) {
+	entries := make([]entities.AuditEntry, 0)
+
+	// latest first
+	for index := len(i.auditLog) - 1; index >= 0; index-- {
+		if matches(i.auditLog[index]) {
+			entries = append(entries, i.auditLog[index])
+		}
 	}
 
-	hasAccess, _ := contains(users, user.ID)
-	if !hasAccess {
+	total := len(entries)
+
+	if pagination != nil {
+		if pagination.Offset >= total {
+			return []entities.AuditEntry{}, total, nil
+		}
+		entries = entries[pagination.Offset:]
+		if pagination.Limit < len(entries) {
+			entries = entries[:pagination.Limit]
+		}
+	}
+
+	return entries, total, nil
+}
+
+func (i *InMem) GetSpecHistory(projectID string, filePath string, pagination *entities.Pagination) ([]entities.Spec, int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	specs := make([]entities.Spec, 0)
+
+	for _, spec := range i.specs {
+		if spec.ProjectID == projectID && spec.FilePath == filePath && spec.Start != 0 && !i.isSessionDeleted(spec.SessionID) {
+			specs = append(specs, *spec)
+		}
+	}
+
+	sort.Slice(specs, func(a, b int) bool {
+		return specs[a].Start > specs[b].Start
+	})
+
+	total := len(specs)
+
+	if pagination != nil {
+		if pagination.Offset >= total {
+			return []entities.Spec{}, total, nil
+		}
+		specs = specs[pagination.Offset:]
+		if pagination.Limit < len(specs) {
+			specs = specs[:pagination.Limit]
+		}
+	}
+
+	return specs, total, nil
+}
+
+func (i *InMem) isSessionDeleted(sessionID string) bool {
+	session, ok := i.sessions[sessionID]
+	return ok && session.DeletedAt != 0
+}
+
+func (i *InMem) DeleteProject(projectID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.projects[projectID]; !ok {
 		return ErrProjectNotFound
 	}
 
-	// this is last user of this project so we can remove it completely
-	if len(users) == 1 {
-		projectSessions, _, err := i.GetProjectSessions(projectID, nil)
-		if err != nil {
+	// soft deleted sessions are removed as well
+	for _, session := range i.sessions {
+		if session.ProjectID != projectID {
+			continue
+		}
+		if err := i.deleteSession(session.ID); err != nil {
 			return err
 		}
+	}
 
-		for _, session := range projectSessions {
-			err := i.DeleteSession(email, session.ID)
-			if err != nil {
-				return err
-			}
+	for id, userProject := range i.userProjects {
+		if userProject.ProjectID == projectID {
+			delete(i.userProjects, id)
 		}
-		delete(i.projects, projectID)
 	}
+
+	delete(i.baselines, projectID)
+	delete(i.projects, projectID)
 	return nil
 }
 
-func (i *InMem) DeleteSession(email string, sessionID string) error {
+func (i *InMem) DeleteSession(sessionID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.deleteSession(sessionID)
+}
+
+func (i *InMem) deleteSession(sessionID string) error {
 	session, ok := i.sessions[sessionID]
 	if !ok {
 		return ErrSessionNotFound
@@ -302,11 +707,152 @@ func (i *InMem) DeleteSession(email string, sessionID string) error {
 	return nil
 }
 
-func (i *InMem) GetProjectSessions(projectID string, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
+func (i *InMem) SetSessionDeleted(sessionID string, deletedAt int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	session, ok := i.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	session.DeletedAt = deletedAt
+	return nil
+}
+
+func (i *InMem) GetDeletedSessions(before int64) ([]entities.Session, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var sessions []entities.Session
+	for _, session := range i.sessions {
+		if session.DeletedAt != 0 && session.DeletedAt < before {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (i *InMem) SetProjectDeleted(projectID string, deletedAt int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	project, ok := i.projects[projectID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	project.DeletedAt = deletedAt
+	return nil
+}
+
+func (i *InMem) SetProjectRetention(projectID string, policy entities.RetentionPolicy) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	project, ok := i.projects[projectID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	project.RetentionKeepLast = policy.KeepLast
+	project.RetentionMaxAgeDays = policy.MaxAgeDays
+	return nil
+}
+
+func (i *InMem) GetProjectsWithRetention() ([]entities.Project, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var projects []entities.Project
+	for _, project := range i.projects {
+		if project.Retention().Enabled() {
+			projects = append(projects, *project)
+		}
+	}
+	return projects, nil
+}
+
+func (i *InMem) SetProjectEstimationSource(projectID string, sourceID string, setBy string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	project, ok := i.projects[projectID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	project.EstimationSourceID = sourceID
+	project.EstimationSourceSetBy = setBy
+	return nil
+}
+
+func (i *InMem) AddProjectEstimationKeys(projectID string, keys []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	project, ok := i.projects[projectID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	project.EstimationKeys = mergeKeys(project.EstimationKeys, keys)
+	return nil
+}
+
+func (i *InMem) SetProjectBaseline(projectID string, baselines []entities.SpecBaseline) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.projects[projectID]; !ok {
+		return ErrProjectNotFound
+	}
+	i.baselines[projectID] = baselines
+	return nil
+}
+
+func (i *InMem) GetProjectBaseline(projectID string) ([]entities.SpecBaseline, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.baselines[projectID], nil
+}
+
+func (i *InMem) GetDeletedProjects(before int64) ([]entities.Project, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var projects []entities.Project
+	for _, project := range i.projects {
+		if project.DeletedAt != 0 && project.DeletedAt < before {
+			projects = append(projects, *project)
+		}
+	}
+	return projects, nil
+}
+
+// MigrateSessions is not needed for in-memory storage, sessions always have all properties
+func (i *InMem) MigrateSessions() (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return 0, nil
+}
+
+// MigrateSpecs is not needed for in-memory storage, specs always have project id
+func (i *InMem) MigrateSpecs() (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return 0, nil
+}
+
+func (i *InMem) GetProjectSessions(projectID string, labels []entities.Label, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getProjectSessions(projectID, labels, pagination)
+}
+
+func (i *InMem) getProjectSessions(projectID string, labels []entities.Label, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error) {
 	var sessions []entities.SessionWithSpecs
 	for _, session := range i.sessions {
-		if session.ProjectID == projectID {
-			sessionWithSpecs, err := i.GetSessionWithSpecs(session.ID)
+		if session.ProjectID == projectID && session.DeletedAt == 0 && entities.HasLabels(session.Labels, labels) {
+			sessionWithSpecs, err := i.getSessionWithSpecs(session.ID)
 			if err != nil {
 				return sessions, 0, err
 			}
@@ -318,27 +864,220 @@ func (i *InMem) GetProjectSessions(projectID string, pagination *entities.Pagina
 }
 
 func (i *InMem) GetSessionWithSpecs(sessionID string) (entities.SessionWithSpecs, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.getSessionWithSpecs(sessionID)
+}
+
+func (i *InMem) getSessionWithSpecs(sessionID string) (entities.SessionWithSpecs, error) {
 	var empty entities.SessionWithSpecs
-	session, err := i.GetSession(sessionID)
+	session, err := i.getSession(sessionID)
 	if err != nil {
 		return empty, err
 	}
 
-	specs, err := i.GetSpecs(sessionID)
+	specs, err := i.getSpecs(sessionID)
 	if err != nil {
 		return empty, err
 	}
 
 	return entities.SessionWithSpecs{
-		ID:        session.ID,
-		ProjectID: session.ProjectID,
-		Start:     session.Start,
-		End:       session.End,
-		Specs:     specs,
+		ID:                 session.ID,
+		ProjectID:          session.ProjectID,
+		Start:              session.Start,
+		End:                session.End,
+		AbortAfterFailures: session.AbortAfterFailures,
+		AbortedBy:          session.AbortedBy,
+		DeletedAt:          session.DeletedAt,
+		Labels:             session.Labels,
+		LabelIndex:         session.LabelIndex,
+		Specs:              specs,
 	}, nil
 }
 
+func (i *InMem) CreateOrganisation(organisation entities.Organisation) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.organisations[organisation.ID] = &organisation
+	return nil
+}
+
+func (i *InMem) GetOrganisationByID(ID string) (*entities.Organisation, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	organisation, ok := i.organisations[ID]
+	if !ok {
+		return nil, ErrOrganisationNotFound
+	}
+	return organisation, nil
+}
+
+func (i *InMem) GetOrganisationByName(name string) (*entities.Organisation, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, organisation := range i.organisations {
+		if organisation.Name == name {
+			return organisation, nil
+		}
+	}
+	return nil, ErrOrganisationNotFound
+}
+
+func (i *InMem) GetOrganisationMembers(organisationID string) ([]entities.OrganisationMember, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var members []entities.OrganisationMember
+	for _, member := range i.organisationMembers {
+		if member.OrganisationID == organisationID {
+			members = append(members, *member)
+		}
+	}
+	return members, nil
+}
+
+func (i *InMem) GetUserOrganisations(userID string) ([]entities.OrganisationMember, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var memberships []entities.OrganisationMember
+	for _, member := range i.organisationMembers {
+		if member.UserID == userID {
+			memberships = append(memberships, *member)
+		}
+	}
+	return memberships, nil
+}
+
+func (i *InMem) AttachUserToOrganisation(userID string, organisationID string, role string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, member := range i.organisationMembers {
+		if member.UserID == userID && member.OrganisationID == organisationID {
+			member.Role = role
+			return nil
+		}
+	}
+
+	id, err := gonanoid.New()
+	if err != nil {
+		return err
+	}
+
+	i.organisationMembers[id] = &entities.OrganisationMember{
+		ID:             id,
+		OrganisationID: organisationID,
+		UserID:         userID,
+		Role:           role,
+	}
+	return nil
+}
+
+func (i *InMem) UnlinkUserFromOrganisation(userID string, organisationID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for id, member := range i.organisationMembers {
+		if member.UserID == userID && member.OrganisationID == organisationID {
+			delete(i.organisationMembers, id)
+			return nil
+		}
+	}
+	return ErrMemberNotFound
+}
+
+func (i *InMem) GetOrganisationProjects(organisationID string) ([]entities.Project, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var projects []entities.Project
+	for _, project := range i.projects {
+		if project.OrganisationID == organisationID {
+			projects = append(projects, *project)
+		}
+	}
+	return projects, nil
+}
+
+func (i *InMem) SetProjectOrganisation(projectID string, organisationID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	project, ok := i.projects[projectID]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	project.OrganisationID = organisationID
+	return nil
+}
+
+func (i *InMem) CreateServiceToken(token entities.ServiceToken) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.serviceTokens[token.ID]; ok {
+		return fmt.Errorf("service token with id %s already exist", token.ID)
+	}
+	i.serviceTokens[token.ID] = &token
+	return nil
+}
+
+func (i *InMem) GetServiceToken(tokenID string) (entities.ServiceToken, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	token, ok := i.serviceTokens[tokenID]
+	if !ok {
+		return entities.ServiceToken{}, ErrServiceTokenNotFound
+	}
+	return *token, nil
+}
+
+func (i *InMem) GetProjectServiceTokens(projectID string) ([]entities.ServiceToken, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var tokens []entities.ServiceToken
+	for _, token := range i.serviceTokens {
+		if token.ProjectID == projectID {
+			tokens = append(tokens, *token)
+		}
+	}
+	return tokens, nil
+}
+
+func (i *InMem) DeleteServiceToken(projectID string, tokenID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	token, ok := i.serviceTokens[tokenID]
+	if !ok || token.ProjectID != projectID {
+		return ErrServiceTokenNotFound
+	}
+	delete(i.serviceTokens, tokenID)
+	return nil
+}
+
+func (i *InMem) TouchServiceToken(tokenID string, usedAt int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	token, ok := i.serviceTokens[tokenID]
+	if !ok {
+		return ErrServiceTokenNotFound
+	}
+	token.LastUsedAt = usedAt
+	return nil
+}
+
 func (i *InMem) CreateApiKey(userID string, key entities.ApiKey) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	_, ok := i.apiKeys[key.ID]
 	if ok {
 		return fmt.Errorf("api key with id %s already exist", key.ID)
@@ -350,6 +1089,9 @@ func (i *InMem) CreateApiKey(userID string, key entities.ApiKey) error {
 }
 
 func (i *InMem) DeleteApiKey(userID string, keyID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	_, ok := i.apiKeys[keyID]
 	if !ok {
 		return ErrApiKeyNotFound
@@ -360,6 +1102,9 @@ func (i *InMem) DeleteApiKey(userID string, keyID string) error {
 }
 
 func (i *InMem) GetApiKeys(userID string) ([]entities.ApiKey, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	var keys []entities.ApiKey
 
 	for _, key := range i.apiKeys {
@@ -372,6 +1117,9 @@ func (i *InMem) GetApiKeys(userID string) ([]entities.ApiKey, error) {
 }
 
 func (i *InMem) GetApiKey(userID string, keyID string) (entities.ApiKey, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	var apiKey entities.ApiKey
 
 	for _, key := range i.apiKeys {
@@ -383,6 +1131,31 @@ func (i *InMem) GetApiKey(userID string, keyID string) (entities.ApiKey, error)
 	return apiKey, nil
 }
 
+func (i *InMem) TrackApiKeyUsage(userID string, keyID string, ip string, usedAt int64, count int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key, ok := i.apiKeys[keyID]
+	if !ok || key.UserID != userID {
+		return ErrApiKeyNotFound
+	}
+
+	key.LastUsedAt = usedAt
+	key.LastIP = ip
+	key.RequestCount += count
+	return nil
+}
+
+// mergeKeys appends keys missing in existing
+func mergeKeys(existing []string, keys []string) []string {
+	for _, key := range keys {
+		if found, _ := contains(existing, key); !found {
+			existing = append(existing, key)
+		}
+	}
+	return existing
+}
+
 func contains(input []string, query string) (bool, int) {
 	for index, item := range input {
 		if item == query {
@@ -10,39 +10,97 @@ var DB Storage
 
 type Storage interface {
 	GetProjectByID(ID string) (*entities.Project, error)
-	GetUserProjectIDByName(userID string, projectName string) (string, error)
+	GetUserProjectIDsByName(userID string, projectName string) ([]string, error)
 	GetUserProjectIDs(userID string) ([]string, error)
 
 	CreateProject(project entities.Project) error
-	AttachProjectToUser(userID string, projectID string) error
-	DeleteProject(email string, projectID string) error
-	GetProjectSessions(projectID string, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error)
+	AttachProjectToUser(userID string, projectID string, role string) error
+	DeleteProject(projectID string) error
+	SetProjectDeleted(projectID string, deletedAt int64) error
+	GetDeletedProjects(before int64) ([]entities.Project, error)
+	SetProjectRetention(projectID string, policy entities.RetentionPolicy) error
+	GetProjectsWithRetention() ([]entities.Project, error)
+	SetProjectEstimationSource(projectID string, sourceID string, setBy string) error
+	AddProjectEstimationKeys(projectID string, keys []string) error
+	SetProjectBaseline(projectID string, baselines []entities.SpecBaseline) error
+	GetProjectBaseline(projectID string) ([]entities.SpecBaseline, error)
+	GetProjectSessions(projectID string, labels []entities.Label, pagination *entities.Pagination) ([]entities.SessionWithSpecs, int, error)
 	GetProjectUsers(projectID string) ([]string, error)
+	GetProjectMembers(projectID string) ([]entities.UserProject, error)
+	SetProjectMemberRole(userID string, projectID string, role string) error
+	UnlinkProjectFromUser(userID string, projectID string) error
+	GetLegacyProjectMembers() ([]entities.UserProject, error)
 
 	GetSession(sessionID string) (entities.Session, error)
 	GetSessionWithSpecs(sessionID string) (entities.SessionWithSpecs, error)
-	CreateSession(projectName string, sessionID string, specs []entities.Spec) (*entities.Session, error)
+	CreateSession(session entities.Session, specs []entities.Spec) (*entities.Session, error)
 	EndSession(sessionID string) error
-	DeleteSession(email string, sessionID string) error
+	AbortSession(sessionID string, abortedBy string) error
+	DeleteSession(sessionID string) error
+	SetSessionDeleted(sessionID string, deletedAt int64) error
+	GetDeletedSessions(before int64) ([]entities.Session, error)
 
-	GetProjectLatestSessions(projectID string, limit int) ([]*entities.Session, error)
+	GetProjectLatestSessions(projectID string, limit int, labels []entities.Label) ([]*entities.Session, error)
+	GetProjectSessionList(projectID string) ([]entities.Session, error)
+	MigrateSessions() (int, error)
+	MigrateSpecs() (int, error)
 
 	CreateSpecs(sessionID string, specs []entities.Spec) error
 	GetSpec(specID string) (entities.Spec, error)
 	GetSpecs(sessionID string) ([]entities.Spec, error)
+	GetProjectFinishedSpecs(projectID string, since int64) ([]entities.Spec, error)
+	GetSpecHistory(projectID string, filePath string, pagination *entities.Pagination) ([]entities.Spec, int, error)
 	StartSpec(sessionID string, machineID string, specID string) error
 	EndSpec(sessionID string, machineID string, isPassed bool) error
 
+	//organisations
+	CreateOrganisation(organisation entities.Organisation) error
+	GetOrganisationByID(ID string) (*entities.Organisation, error)
+	GetOrganisationByName(name string) (*entities.Organisation, error)
+	GetOrganisationMembers(organisationID string) ([]entities.OrganisationMember, error)
+	GetUserOrganisations(userID string) ([]entities.OrganisationMember, error)
+	AttachUserToOrganisation(userID string, organisationID string, role string) error
+	UnlinkUserFromOrganisation(userID string, organisationID string) error
+	GetOrganisationProjects(organisationID string) ([]entities.Project, error)
+	SetProjectOrganisation(projectID string, organisationID string) error
+
+	//service tokens
+	CreateServiceToken(token entities.ServiceToken) error
+	GetServiceToken(tokenID string) (entities.ServiceToken, error)
+	GetProjectServiceTokens(projectID string) ([]entities.ServiceToken, error)
+	DeleteServiceToken(projectID string, tokenID string) error
+	TouchServiceToken(tokenID string, usedAt int64) error
+
+	//audit log
+	CreateAuditEntry(entry entities.AuditEntry) error
+	GetProjectAuditLog(projectID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error)
+	GetUserAuditLog(userID string, pagination *entities.Pagination) ([]entities.AuditEntry, int, error)
+
 	//auth
 	CreateUser(user entities.User) error
 	GetUserByEmail(email string) (*entities.User, error)
+	GetUserByID(userID string) (*entities.User, error)
+	GetUserByOIDCSubject(issuer string, subject string) (*entities.User, error)
+	LinkUserToOIDCSubject(userID string, issuer string, subject string) error
 	UpdatePassword(userID string, newPassword string) error
+	RevokeUserTokens(userID string, revokedAt int64) error
+
+	//refresh tokens
+	CreateRefreshToken(token entities.RefreshToken) error
+	ConsumeRefreshToken(tokenID string) (entities.RefreshToken, error)
+	DeleteUserRefreshTokens(userID string) error
+
+	//password reset
+	CreatePasswordReset(reset entities.PasswordReset) error
+	ConsumePasswordReset(resetID string) (entities.PasswordReset, error)
+	DeleteUserPasswordResets(userID string) error
 
 	//api keys
 	CreateApiKey(userID string, key entities.ApiKey) error
 	DeleteApiKey(userID string, keyID string) error
 	GetApiKeys(userID string) ([]entities.ApiKey, error)
 	GetApiKey(userID string, keyID string) (entities.ApiKey, error)
+	TrackApiKeyUsage(userID string, keyID string, ip string, usedAt int64, count int64) error
 }
 
 var ErrProjectNotFound = errors.New("project not found")
@@ -50,3 +108,9 @@ var ErrSessionNotFound = errors.New("session not found")
 var ErrSpecNotFound = errors.New("spec not found")
 var ErrSessionFinished = errors.New("session already finished")
 var ErrApiKeyNotFound = errors.New("api key not found")
+var ErrUserNotFound = errors.New("user not found")
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+var ErrPasswordResetNotFound = errors.New("password reset not found")
+var ErrMemberNotFound = errors.New("project member not found")
+var ErrOrganisationNotFound = errors.New("organisation not found")
+var ErrServiceTokenNotFound = errors.New("service token not found")
@@ -8,28 +8,88 @@ import {
 import { onError } from '@apollo/client/link/error';
 import { setContext } from '@apollo/client/link/context';
 
+const uri = 'https://split-specs.appspot.com/query';
+
 const token = () => localStorage.getItem('token');
-const unsetToken = () => localStorage.removeItem('token');
 
-const httpLink = createHttpLink({
-    uri: 'https://split-specs.appspot.com/query'
-});
+export const storeTokens = ({ accessToken, refreshToken, expireAt }) => {
+    localStorage.setItem('token', accessToken);
+    localStorage.setItem('refreshToken', refreshToken);
+    localStorage.setItem('expireAt', expireAt);
+};
+
+export const unsetTokens = () => {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    localStorage.removeItem('expireAt');
+};
+
+// sso login redirects back with tokens in url fragment
+const storeFragmentTokens = () => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (!params.get('accessToken')) {
+        return;
+    }
+
+    storeTokens({
+        accessToken: params.get('accessToken'),
+        refreshToken: params.get('refreshToken'),
+        expireAt: params.get('expireAt')
+    });
+    window.history.replaceState(null, '', window.location.pathname);
+};
+
+storeFragmentTokens();
+
+// access token is short-lived, so it is renewed with refresh token a minute before expiration
+const refreshTokens = async () => {
+    const refreshToken = localStorage.getItem('refreshToken');
+    const expireAt = Number(localStorage.getItem('expireAt'));
+
+    if (!refreshToken || expireAt - 60 > Date.now() / 1000) {
+        return;
+    }
+
+    const response = await fetch(uri, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            query: `mutation refreshToken($token: String!) {
+                refreshToken(token: $token) { accessToken refreshToken expireAt }
+            }`,
+            variables: { token: refreshToken }
+        })
+    });
+
+    const { data } = await response.json();
+    if (data && data.refreshToken) {
+        storeTokens(data.refreshToken);
+    } else {
+        unsetTokens();
+    }
+};
+
+const httpLink = createHttpLink({ uri });
 
 const errorLink = onError(({ graphQLErrors, networkError, response }) => {
     if (networkError) {
         if (networkError.statusCode === 401) {
-            unsetToken();
+            unsetTokens();
         }
         console.error(`[Network error]: ${networkError}`);
     }
 });
 
-const authLink = setContext((_, { headers }) => ({
-    headers: {
-        ...headers,
-        Authorization: token() ?? ''
-    }
-}));
+const authLink = setContext(async (_, { headers }) => {
+    await refreshTokens();
+
+    return {
+        headers: {
+            ...headers,
+            Authorization: token() ?? ''
+        }
+    };
+});
 
 const cache = new InMemoryCache({
     typePolicies: {
@@ -2,13 +2,21 @@ import { gql } from '@apollo/client';
 
 export const SIGN_UP = gql`
     mutation register($email: String!, $password: String!) {
-        register(input: { email: $email, password: $password })
+        register(input: { email: $email, password: $password }) {
+            accessToken
+            refreshToken
+            expireAt
+        }
     }
 `;
 
 export const SIGN_IN = gql`
     mutation login($email: String!, $password: String!) {
-        login(input: { email: $email, password: $password })
+        login(input: { email: $email, password: $password }) {
+            accessToken
+            refreshToken
+            expireAt
+        }
     }
 `;
 
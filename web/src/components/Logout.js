@@ -2,11 +2,11 @@ import { memo, useCallback } from 'react';
 import { withRouter } from 'react-router-dom';
 import { BiExit } from 'react-icons/bi';
 
-import client, { isLoggedInVar } from '../apollo';
+import client, { isLoggedInVar, unsetTokens } from '../apollo';
 
 const Logout = ({ history, className }) => {
     const onClick = useCallback(() => {
-        localStorage.removeItem('token');
+        unsetTokens();
         sessionStorage.clear();
         isLoggedInVar(false);
         client.clearStore().then(() => {
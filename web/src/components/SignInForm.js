@@ -2,7 +2,7 @@ import { memo, useCallback, useState } from 'react';
 import { Link } from 'react-router-dom';
 import { useMutation } from '@apollo/client';
 
-import { isLoggedInVar } from '../apollo';
+import { isLoggedInVar, storeTokens } from '../apollo';
 import { SIGN_IN } from '../apollo/mutation';
 
 import Spinner from '../components/Spinner';
@@ -10,7 +10,7 @@ import Spinner from '../components/Spinner';
 const SignInForm = () => {
     const [signIn, { error, loading }] = useMutation(SIGN_IN, {
         onCompleted: (data) => {
-            localStorage.setItem('token', data.login);
+            storeTokens(data.login);
             isLoggedInVar(true);
         }
     });
@@ -94,6 +94,15 @@ const SignInForm = () => {
                                 {loading ? <Spinner /> : <p>Sign in</p>}
                             </button>
                         </div>
+
+                        <p className="text-center text-xs text-gray-600 mt-4">
+                            <a
+                                className="text-blue-600 font-semibold"
+                                href="/auth/oidc/login"
+                            >
+                                Sign in with SSO
+                            </a>
+                        </p>
                     </form>
                 </div>
             </div>
@@ -1,7 +1,7 @@
 import { memo, useCallback, useState } from 'react';
 import { useMutation } from '@apollo/client';
 
-import { isLoggedInVar } from '../apollo';
+import { isLoggedInVar, storeTokens } from '../apollo';
 import { SIGN_UP } from '../apollo/mutation';
 
 import Spinner from '../components/Spinner';
@@ -9,7 +9,7 @@ import Spinner from '../components/Spinner';
 const SignUpForm = ({ history }) => {
     const [signUp, { error, loading }] = useMutation(SIGN_UP, {
         onCompleted: (data) => {
-            localStorage.setItem('token', data.register);
+            storeTokens(data.register);
             isLoggedInVar(true);
             history.replace('/');
         }